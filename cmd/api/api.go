@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,7 +22,9 @@ import (
 	"github.com/high-la/gopher-social/docs" // This is required to generate swagger docs
 	"github.com/high-la/gopher-social/internal/auth"
 	"github.com/high-la/gopher-social/internal/mailer"
+	"github.com/high-la/gopher-social/internal/oidc"
 	"github.com/high-la/gopher-social/internal/ratelimiter"
+	"github.com/high-la/gopher-social/internal/storage"
 	"github.com/high-la/gopher-social/internal/store"
 	"github.com/high-la/gopher-social/internal/store/cache"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
@@ -37,6 +40,62 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	// limits activation email resends per address
+	activationRateLimiter ratelimiter.Config
+	sweeper               sweeperConfig
+	oidc                  oidcConfig
+	comments              commentsConfig
+	posts                 postsConfig
+	trash                 trashConfig
+	media                 mediaConfig
+}
+
+type mediaConfig struct {
+	// where uploads are kept, "local" or "s3"
+	backend string
+	// directory of the local backend, served under /v1/media
+	dir string
+	s3  storage.S3Config
+	// in bytes
+	maxUploadSize  int64
+	maxAttachments int
+	// thumbnails fit in a square of this many pixels
+	thumbnailSize int
+	// images decoded at the same time, each one can take hundreds of MB
+	maxConcurrentProcessing int
+}
+
+type trashConfig struct {
+	// how long deleted users, posts and comments can be restored
+	retention time.Duration
+}
+
+type postsConfig struct {
+	// how often scheduled posts are checked for publishing, 0 disables it
+	publishInterval time.Duration
+}
+
+type commentsConfig struct {
+	// how deep replies can be nested, 0 disables replies
+	maxDepth int
+}
+
+type oidcConfig struct {
+	providers []oidcProviderConfig
+	// how long a user has to come back from the provider
+	stateExpiry time.Duration
+}
+
+type oidcProviderConfig struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+}
+
+type sweeperConfig struct {
+	interval               time.Duration
+	unactivatedGracePeriod time.Duration
 }
 
 type redisConfig struct {
@@ -47,14 +106,36 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic     basicConfig
+	token     tokenConfig
+	lockout   lockoutConfig
+	twoFactor twoFactorConfig
+}
+
+type twoFactorConfig struct {
+	// shown by authenticator apps next to the account
+	issuer          string
+	challengeExpiry time.Duration
+	// users with this role or a higher one must enroll, empty to disable
+	requiredRole string
+}
+
+type lockoutConfig struct {
+	maxAccountAttempts int
+	maxIPAttempts      int
+	window             time.Duration
 }
 
 type tokenConfig struct {
-	secret string
-	expiry time.Duration
-	issuer string
+	secret        string
+	expiry        time.Duration
+	refreshExpiry time.Duration
+	// lifetime of the tokens admins get to act as another user
+	impersonationExpiry time.Duration
+	issuer              string
+	// asymmetric signing, used instead of the secret when set
+	signingKeyFile       string
+	verificationKeyFiles []string
 }
 
 type basicConfig struct {
@@ -63,9 +144,11 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	sendGrid  sendGridConfig
-	fromEmail string
-	expiry    time.Duration
+	sendGrid            sendGridConfig
+	fromEmail           string
+	expiry              time.Duration
+	passwordResetExpiry time.Duration
+	magicLinkExpiry     time.Duration
 }
 
 type sendGridConfig struct {
@@ -87,6 +170,14 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	// keyed by email instead of IP
+	activationRateLimiter ratelimiter.Limiter
+	// keyed by provider name
+	oidcProviders map[string]*oidc.Provider
+	// uploaded media
+	blobs storage.Blobs
+	// one slot per image being processed
+	mediaSlots chan struct{}
 }
 
 func (app *application) mount() http.Handler {
@@ -103,7 +194,7 @@ func (app *application) mount() http.Handler {
 		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
 		AllowedOrigins: []string{"https://*", "http://*"},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -116,6 +207,9 @@ func (app *application) mount() http.Handler {
 	// processing should be stopped.
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	// Public keys to verify the issued tokens
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	// Group routes
 	r.Route("/v1", func(r chi.Router) {
 
@@ -130,12 +224,20 @@ func (app *application) mount() http.Handler {
 			httpSwagger.URL(docsURL), // The url pointing to API definition
 		))
 
+		// Uploaded media of the local backend, public like a CDN would be
+		if local, ok := app.blobs.(*storage.Local); ok {
+			r.Handle("/media/*", http.StripPrefix("/v1/media", local))
+		}
+
 		// /posts
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite))
 
 			// 1. Routes that DO NOT need the context middleware (no ID yet)
 			r.Post("/", app.createPostHandler)
+			// deleted posts are out of reach of the context middleware
+			r.Post("/{id}/restore", app.restorePostHandler)
 
 			// 2. Routes that DO need the context middleware (must have an ID)
 			r.Route("/{id}", func(r chi.Router) {
@@ -144,6 +246,49 @@ func (app *application) mount() http.Handler {
 				r.Get("/", app.getPostHandler)                                           // Maps to GET /posts/{id}
 				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler)) // Maps to PATCH /posts/{id}
 				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))    // Maps to DELETE /posts/{id}
+
+				r.Post("/attachments", app.uploadAttachmentHandler)
+				r.Delete("/attachments/{attachmentID}", app.checkPostOwnership("moderator", app.deleteAttachmentHandler))
+
+				r.Post("/poll/votes", app.voteHandler)
+				r.Delete("/poll/votes", app.unvoteHandler)
+
+				r.Get("/revisions", app.listPostRevisionsHandler)
+				r.Get("/revisions/diff", app.diffPostRevisionsHandler)
+				r.Get("/revisions/{version}", app.getPostRevisionHandler)
+
+				r.Put("/repost", app.repostHandler)
+				r.Delete("/repost", app.unrepostHandler)
+
+				r.Put("/pin", app.pinPostHandler)
+				r.Delete("/pin", app.unpinPostHandler)
+
+				r.Put("/bookmark", app.bookmarkPostHandler)
+				r.Delete("/bookmark", app.unbookmarkPostHandler)
+
+				r.Get("/reactions", app.getPostReactionsHandler)
+				r.Put("/reactions/{kind}", app.reactToPostHandler)
+				r.Delete("/reactions/{kind}", app.unreactToPostHandler)
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.listCommentsHandler)
+					r.Post("/", app.createCommentHandler)
+
+					r.Post("/{commentID}/restore", app.restoreCommentHandler)
+					// placeholders keep their replies reachable
+					r.With(app.listedCommentContextMiddleware).Get("/{commentID}/replies", app.listRepliesHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentsContextMiddleware)
+
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+
+						r.Get("/reactions", app.getCommentReactionsHandler)
+						r.Put("/reactions/{kind}", app.reactToCommentHandler)
+						r.Delete("/reactions/{kind}", app.unreactToCommentHandler)
+					})
+				})
 			})
 		})
 
@@ -153,17 +298,103 @@ func (app *application) mount() http.Handler {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
 			//
+			r.Route("/me", func(r chi.Router) {
+
+				// personal access tokens, managed from a logged in session only
+				r.Route("/tokens", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.SessionOnlyMiddleware)
+
+					r.Get("/", app.listAPIKeysHandler)
+					r.Post("/", app.createAPIKeyHandler)
+					r.Get("/{tokenID}", app.getAPIKeyHandler)
+					r.Delete("/{tokenID}", app.deleteAPIKeyHandler)
+				})
+
+				// devices the user is logged in on
+				r.Route("/sessions", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.SessionOnlyMiddleware)
+
+					r.Get("/", app.listSessionsHandler)
+					r.Delete("/{sessionID}", app.revokeSessionHandler)
+				})
+
+				// external provider accounts
+				r.Route("/identities", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.SessionOnlyMiddleware)
+
+					r.Get("/", app.listIdentitiesHandler)
+					r.Post("/{provider}", app.linkIdentityHandler)
+					r.Delete("/{provider}", app.unlinkIdentityHandler)
+				})
+
+				r.Route("/bookmarks", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite))
+
+					r.Get("/", app.listBookmarksHandler)
+				})
+
+				r.Route("/trash", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite))
+
+					r.Get("/", app.listTrashHandler)
+				})
+
+				r.Route("/pins", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite))
+
+					r.Put("/", app.reorderPinsHandler)
+				})
+
+				r.Route("/drafts", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite))
+
+					r.Get("/", app.listDraftsHandler)
+				})
+
+				r.Route("/mentions", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Use(app.ScopesMiddleware(store.ScopeFeedRead, store.ScopeFeedRead))
+
+					r.Get("/", app.listMentionsHandler)
+				})
+
+				// also reachable by users who must enroll before logging in
+				r.Route("/2fa", func(r chi.Router) {
+					r.Use(app.TwoFactorEnrollmentMiddleware)
+					r.Use(app.SessionOnlyMiddleware)
+
+					r.Post("/enroll", app.enrollTwoFactorHandler)
+					r.Post("/confirm", app.confirmTwoFactorHandler)
+					r.Delete("/", app.disableTwoFactorHandler)
+				})
+			})
+
 			r.Route("/{id}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.ScopesMiddleware(store.ScopeUsersRead, store.ScopeUsersWrite))
 
 				r.Get("/", app.getUserHandler) // Maps to GET /users/{id}
+				r.With(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite)).Get("/profile", app.getUserProfileHandler)
+				r.With(app.ScopesMiddleware(store.ScopePostsRead, store.ScopePostsWrite)).Get("/posts", app.listUserPostsHandler)
+				r.With(app.SessionOnlyMiddleware).Delete("/", app.deleteUserHandler)
+				r.Post("/restore", app.restoreUserHandler)
 
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+
+				r.With(app.SessionOnlyMiddleware).Post("/impersonate", app.impersonateUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.ScopesMiddleware(store.ScopeFeedRead, store.ScopeFeedRead))
 				r.Get("/feed", app.getUserFeedHandler)
 			})
 
@@ -172,6 +403,16 @@ func (app *application) mount() http.Handler {
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/2fa/verify", app.verifyTwoFactorHandler)
+			r.Post("/magic-link", app.requestMagicLinkHandler)
+			r.Post("/magic-link/consume", app.consumeMagicLinkHandler)
+			r.Get("/oidc/{provider}/login", app.oidcLoginHandler)
+			r.Get("/oidc/{provider}/callback", app.oidcCallbackHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.With(app.AuthTokenMiddleware, app.SessionOnlyMiddleware).Post("/logout", app.logoutHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
 		})
 	})
 
@@ -201,6 +442,13 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	// Background workers, stopped with the server
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	app.startWorkers(workersCtx, &workers)
+
 	// Graceful shutdown
 	shutdown := make(chan error)
 
@@ -216,7 +464,11 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
-		shutdown <- srv.Shutdown(ctx)
+		stopWorkers()
+		err := srv.Shutdown(ctx)
+		workers.Wait()
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
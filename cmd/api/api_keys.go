@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type apiKeyKey string
+
+const apiKeyCtx apiKeyKey = "apiKey"
+
+type CreateAPIKeyPayload struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write feed:read users:read users:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyWithToken struct {
+	*store.APIKey
+	Token string `json:"token"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Creates an API key
+//	@Description	Creates a personal access token. The token is only returned once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAPIKeyPayload	true	"API key"
+//	@Success		201		{object}	APIKeyWithToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload CreateAPIKeyPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.ExpiresAt != nil && payload.ExpiresAt.Before(time.Now()) {
+		app.badRequestResponse(w, r, fmt.Errorf("expires_at must be in the future"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	prefix, plainToken, err := generateAPIKey()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	key := &store.APIKey{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Prefix: prefix,
+		Token:  hashToken(plainToken),
+		Scopes: payload.Scopes,
+		Expiry: payload.ExpiresAt,
+	}
+
+	err = app.store.APIKeys.Create(r.Context(), key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("api key created", "user_id", user.ID, "api_key_id", key.ID, "scopes", key.Scopes)
+
+	err = app.jsonResponse(w, http.StatusCreated, APIKeyWithToken{APIKey: key, Token: plainToken})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAPIKeys godoc
+//
+//	@Summary		Lists API keys
+//	@Description	Lists the personal access tokens of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.APIKey
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+
+	keys, err := app.store.APIKeys.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, keys)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetAPIKey godoc
+//
+//	@Summary		Fetches an API key
+//	@Description	Fetches a personal access token of the authenticated user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int	true	"API key ID"
+//	@Success		200		{object}	store.APIKey
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [get]
+func (app *application) getAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	key, err := app.store.APIKeys.GetByID(r.Context(), id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAPIKey godoc
+//
+//	@Summary		Deletes an API key
+//	@Description	Revokes a personal access token of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int	true	"API key ID"
+//	@Success		204		{string}	string
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	err = app.store.APIKeys.Delete(r.Context(), id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("api key deleted", "user_id", user.ID, "api_key_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// generateAPIKey returns a new token along with its public prefix, which is
+// kept in clear to help users recognise their keys.
+func generateAPIKey() (string, string, error) {
+
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := store.APIKeyPrefix + hex.EncodeToString(id)
+	token := prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return prefix, token, nil
+}
+
+func getAPIKeyFromContext(r *http.Request) *store.APIKey {
+
+	key, _ := r.Context().Value(apiKeyCtx).(*store.APIKey)
+	return key
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	// the mock store scopes every key to users:read
+	apiKey := store.APIKeyPrefix + "0123abcd_secret"
+
+	newRequest := func(t *testing.T, path string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+
+		return req
+	}
+
+	t.Run("should allow requests within the key scopes", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, "/v1/users/1"), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should forbid requests outside the key scopes", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, "/v1/users/feed"), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should not let api keys manage api keys", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, "/v1/users/me/tokens"), mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+}
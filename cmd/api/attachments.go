@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/media"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// multipartOverhead is allowed on top of the upload size for the headers
+// and boundaries of the form.
+const multipartOverhead = 64 << 10
+
+// UploadAttachment godoc
+//
+//	@Summary		Attaches an image to a post
+//	@Description	Attaches a JPEG, PNG or GIF image, sent as the file field of a multipart form, to the user's post. The type is sniffed from the content, the metadata stripped and a thumbnail generated. Posts can have a limited number of attachments
+//	@Tags			posts
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			file	formData	file	true	"Image"
+//	@Success		201		{object}	store.Attachment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/attachments [post]
+func (app *application) uploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	if post.UserID != getUserFromContext(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// spares processing an upload the store would refuse; the store enforces
+	// the limit against concurrent uploads
+	maxAttachments := app.config.media.maxAttachments
+	if len(post.Attachments) >= maxAttachments {
+		app.badRequestResponse(w, r, fmt.Errorf("a post can have up to %d attachments", maxAttachments))
+		return
+	}
+
+	data, err := app.readUpload(w, r, "file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			app.payloadTooLargeResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	img, err := app.processImage(r.Context(), data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrTooManyPixels), errors.Is(err, media.ErrTooManyFrames), errors.Is(err, media.ErrInvalidImage):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	name := fmt.Sprintf("posts/%d/%s", post.ID, uuid.New().String())
+
+	attachment := &store.Attachment{
+		PostID:       post.ID,
+		Key:          name + media.Extensions[img.ContentType],
+		ThumbnailKey: name + "_thumb" + media.Extensions[img.ThumbnailContentType],
+		ContentType:  img.ContentType,
+		Size:         int64(len(img.Data)),
+		Width:        img.Width,
+		Height:       img.Height,
+	}
+
+	ctx := r.Context()
+
+	err = app.blobs.Put(ctx, attachment.Key, img.Data, img.ContentType)
+	if err == nil {
+		err = app.blobs.Put(ctx, attachment.ThumbnailKey, img.Thumbnail, img.ThumbnailContentType)
+	}
+	if err == nil {
+		err = app.store.Attachments.Create(ctx, attachment, maxAttachments)
+	}
+	if err != nil {
+		// nothing refers to the blobs yet
+		if err := app.deleteBlobs(context.WithoutCancel(ctx), attachment); err != nil {
+			app.logger.Warnw("unable to delete the blobs of a failed upload", "key", attachment.Key, "error", err)
+		}
+		switch {
+		case errors.Is(err, store.ErrTooManyAttachments):
+			app.badRequestResponse(w, r, fmt.Errorf("a post can have up to %d attachments", maxAttachments))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	attachment.URL = app.blobs.URL(attachment.Key)
+	attachment.ThumbnailURL = app.blobs.URL(attachment.ThumbnailKey)
+
+	err = app.jsonResponse(w, http.StatusCreated, attachment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// processImage runs media.Process once a slot is free, so that concurrent
+// uploads can not exhaust the memory.
+func (app *application) processImage(ctx context.Context, data []byte) (*media.Image, error) {
+
+	select {
+	case app.mediaSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-app.mediaSlots }()
+
+	return media.Process(data, app.config.media.thumbnailSize)
+}
+
+// readUpload reads the file sent as the given field of a multipart form, up
+// to the maximum upload size.
+func (app *application) readUpload(w http.ResponseWriter, r *http.Request, field string) ([]byte, error) {
+
+	maxSize := app.config.media.maxUploadSize
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing %s field", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() != field {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(data)) > maxSize {
+			return nil, &http.MaxBytesError{Limit: maxSize}
+		}
+
+		return data, nil
+	}
+}
+
+// DeleteAttachment godoc
+//
+//	@Summary		Removes an attachment from a post
+//	@Description	Removes the attachment from the post, its images being deleted in the background. Moderators can remove the attachments of any post
+//	@Tags			posts
+//	@Param			id				path	int	true	"Post ID"
+//	@Param			attachmentID	path	int	true	"Attachment ID"
+//	@Success		204
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/attachments/{attachmentID} [delete]
+func (app *application) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.Attachments.Detach(r.Context(), post.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setAttachmentURLs fills in where the images of the attachments are
+// downloaded from.
+func (app *application) setAttachmentURLs(attachments store.Attachments) {
+
+	for i := range attachments {
+		attachments[i].URL = app.blobs.URL(attachments[i].Key)
+		attachments[i].ThumbnailURL = app.blobs.URL(attachments[i].ThumbnailKey)
+	}
+}
+
+// setFeedAttachmentURLs does setAttachmentURLs for every post of the list.
+func (app *application) setFeedAttachmentURLs(posts []store.PostWithMetadata) {
+
+	for i := range posts {
+		app.setAttachmentURLs(posts[i].Attachments)
+	}
+}
+
+// deleteBlobs deletes the images of the attachment.
+func (app *application) deleteBlobs(ctx context.Context, attachment *store.Attachment) error {
+
+	for _, key := range []string{attachment.Key, attachment.ThumbnailKey} {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sweepAttachments deletes the images of the attachments that were removed
+// from their post or whose post was purged, then the attachments.
+func (app *application) sweepAttachments(ctx context.Context) error {
+
+	const batchSize = 100
+
+	var swept int
+
+	for {
+		attachments, err := app.store.Attachments.GetDetached(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, attachment := range attachments {
+			// retried on the next run
+			if err := app.deleteBlobs(ctx, &attachment); err != nil {
+				return err
+			}
+
+			if err := app.store.Attachments.Delete(ctx, attachment.ID); err != nil {
+				return err
+			}
+
+			swept++
+		}
+
+		if len(attachments) < batchSize {
+			break
+		}
+	}
+
+	if swept > 0 {
+		app.logger.Infow("attachments swept", "attachments", swept)
+	}
+
+	return nil
+}
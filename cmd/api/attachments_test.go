@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestAttachments(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+		media: mediaConfig{
+			maxUploadSize:  1 << 20,
+			maxAttachments: 2,
+			thumbnailSize:  16,
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 64, 32))); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(t *testing.T, userID int64, data []byte) *http.Response {
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		// the claimed type is ignored
+		part, _ := form.CreateFormFile("file", "gopher.txt")
+		part.Write(data)
+		form.Close()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/attachments", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should attach an image to the post", func(t *testing.T) {
+		resp := upload(t, 1, img.Bytes())
+		checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+
+		var res struct {
+			Data store.Attachment `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		attachment := res.Data
+		if attachment.ContentType != "image/png" || attachment.Width != 64 || attachment.Height != 32 {
+			t.Errorf("got %s %dx%d, want image/png 64x32", attachment.ContentType, attachment.Width, attachment.Height)
+		}
+
+		for _, url := range []string{attachment.URL, attachment.ThumbnailURL} {
+			path, ok := strings.CutPrefix(url, "http://localhost:8080")
+			if !ok || !strings.HasPrefix(path, "/v1/media/posts/1/") {
+				t.Fatalf("unexpected URL %s", url)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, path, http.NoBody)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+
+			if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+				t.Errorf("got %s served as %s", path, ct)
+			}
+		}
+	})
+
+	t.Run("should only let the author attach images", func(t *testing.T) {
+		resp := upload(t, 2, img.Bytes())
+		checkResponseCode(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("should reject what is not an image", func(t *testing.T) {
+		resp := upload(t, 1, []byte("hello gophers"))
+		checkResponseCode(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should reject files over the size limit", func(t *testing.T) {
+		resp := upload(t, 1, append(img.Bytes(), make([]byte, 1<<20)...))
+		checkResponseCode(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("should limit the attachments of a post", func(t *testing.T) {
+		app.config.media.maxAttachments = 1
+		defer func() { app.config.media.maxAttachments = 2 }()
+
+		resp := upload(t, 1, img.Bytes())
+		checkResponseCode(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should show the attachments with the post", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/posts/1", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var res struct {
+			Data store.Post `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Data.Attachments) != 1 || res.Data.Attachments[0].URL != "http://localhost:8080/v1/media/posts/1/mock.png" {
+			t.Errorf("got attachments %+v", res.Data.Attachments)
+		}
+		if strings.Contains(rr.Body.String(), "thumbnail_key") {
+			t.Error("the blob keys should not be exposed")
+		}
+	})
+
+	t.Run("should remove an attachment", func(t *testing.T) {
+		remove := func(userID int64, path string) int {
+			token, _ := app.generateToken(userID, time.Minute, nil)
+
+			req, _ := http.NewRequest(http.MethodDelete, path, http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			return executeRequest(req, mux).Code
+		}
+
+		checkResponseCode(t, http.StatusForbidden, remove(2, "/v1/posts/1/attachments/1"))
+		checkResponseCode(t, http.StatusNotFound, remove(1, "/v1/posts/1/attachments/7"))
+		checkResponseCode(t, http.StatusNoContent, remove(1, "/v1/posts/1/attachments/1"))
+	})
+}
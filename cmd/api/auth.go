@@ -4,8 +4,11 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,6 +17,18 @@ import (
 	"github.com/high-la/gopher-social/internal/store"
 )
 
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+// dummyUser carries a password hash compared against when the email is
+// unknown, so both failure paths spend the same bcrypt time
+var dummyUser = func() *store.User {
+	user := &store.User{}
+	_ = user.Password.Set("gopher-social-dummy-password")
+	return user
+}()
+
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
@@ -73,12 +88,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	// .
 	plainToken := uuid.New().String()
 
-	// Store
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
-
 	// store user
-	err = app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.expiry)
+	err = app.store.Users.CreateAndInvite(ctx, user, hashToken(plainToken), app.config.mail.expiry)
 	if err != nil {
 
 		switch err {
@@ -116,7 +127,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		// Rollback user creation if sending mail failed
 		// Use Background context for the cleanup to ensure it has time to run
 
-		err = app.store.Users.Delete(context.Background(), user.ID)
+		err = app.store.Users.Purge(context.Background(), user.ID)
 		if err != nil {
 			app.logger.Errorw("error deleting user during user registration roll back", "error", err)
 		}
@@ -142,14 +153,16 @@ type CreateUserTokenPayload struct {
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates an access and refresh token pair for a user. Users with two-factor authentication get a challenge token to redeem at /authentication/2fa/verify instead
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	AuthTokens				"Tokens"
+//	@Success		202		{object}	TwoFactorChallenge		"Two-factor code required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -169,8 +182,148 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	// refuse early if the account or the client is locked out
+	locked, err := app.isLoginLocked(ctx, payload.Email, ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if locked {
+		app.logger.Warnw("login attempt while locked out", "email", payload.Email, "ip", ip)
+		app.loginLockedResponse(w, r, strconv.Itoa(int(app.config.auth.lockout.window.Seconds())))
+		return
+	}
+
 	// fetch the user (check if the user exists) from the payload
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			_ = dummyUser.Password.Compare(payload.Password)
+			app.recordLoginAttempt(ctx, payload.Email, ip, false, "unknown email")
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// compare the password with the stored hash
+	err = user.Password.Compare(payload.Password)
+	if err != nil {
+		app.recordLoginAttempt(ctx, payload.Email, ip, false, "invalid password")
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid credentials"))
+		return
+	}
+
+	app.loginResponse(w, r, user)
+}
+
+// loginResponse answers a login whose first factor has been checked. With
+// two-factor authentication it only earns a challenge, users who must enroll
+// get an enrollment token and the others their tokens.
+func (app *application) loginResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+
+	ctx := r.Context()
+
+	totp, err := app.getEnabledTOTP(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp != nil {
+		app.twoFactorChallengeResponse(w, r, user)
+		return
+	}
+
+	app.recordLoginAttempt(ctx, user.Email, clientIP(r), true, "")
+
+	required, err := app.isTwoFactorRequired(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if required {
+		app.twoFactorEnrollmentResponse(w, r, user)
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// .
+	err = app.jsonResponse(w, http.StatusCreated, tokens)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	AuthTokens
+//	@Success		201		{object}	AuthTokens	"Two-factor enrollment required"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload RefreshTokenPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	plainToken := uuid.New().String()
+	next := &store.RefreshToken{
+		Token:  hashToken(plainToken),
+		Expiry: time.Now().Add(app.config.auth.token.refreshExpiry),
+	}
+
+	err = app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, next)
+	if err != nil {
+		switch err {
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reuse detected, family revoked", "ip", clientIP(r))
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, next.UserID)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -181,25 +334,311 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// a session opened before two-factor became required for the role of
+	// the user is ended, only the enrollment token is handed out
+	totp, err := app.getEnabledTOTP(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp == nil {
+		required, err := app.isTwoFactorRequired(ctx, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if required {
+			err = app.store.RefreshTokens.RevokeFamily(ctx, next.FamilyID)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			app.twoFactorEnrollmentResponse(w, r, user)
+			return
+		}
+	}
+
+	// the family id is the session id
+	_, err = app.store.Sessions.Touch(ctx, next.FamilyID, clientIP(r))
+	if err != nil && err != store.ErrNotFound {
+		app.logger.Errorw("error recording session activity", "session_id", next.FamilyID, "error", err)
+	}
+
+	accessToken, err := app.generateAccessToken(next.UserID, next.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.expiry.Seconds()),
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, tokens)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"omitempty,max=255"`
+	Everywhere   bool   `json:"everywhere"`
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the current access token and, when given, its refresh token. With everywhere set, every token of the user is revoked
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		LogoutPayload	false	"Logout options"
+//	@Success		204		{string}	string			"Logged out"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload LogoutPayload
+
+	// the body is optional
+	err := readJSON(w, r, &payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	claims := getClaimsFromContext(r)
+	ctx := r.Context()
+
+	// revoke the presented access token until it expires on its own
+	err = app.revokeToken(ctx, claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// end the session of the access token, which revokes its refresh tokens
+	if sid, _ := claims["sid"].(string); sid != "" {
+		err = app.store.Sessions.Revoke(ctx, sid, user.ID)
+		if err != nil && err != store.ErrNotFound {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if payload.Everywhere {
+		err = app.revokeAllTokens(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.logger.Infow("user logged out everywhere", "user_id", user.ID, "ip", clientIP(r))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if payload.RefreshToken != "" {
+		err = app.store.RefreshTokens.RevokeFamilyByToken(ctx, payload.RefreshToken, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.logger.Infow("user logged out", "user_id", user.ID, "ip", clientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllTokens logs the user out of every device: all sessions and their
+// refresh tokens are revoked and every access token issued so far is denylisted.
+func (app *application) revokeAllTokens(ctx context.Context, userID int64) error {
+
+	err := app.store.RefreshTokens.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = app.store.Sessions.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return app.denylist().RevokeUser(ctx, userID, time.Now().Add(app.config.auth.token.expiry))
+}
+
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in"`
+	// set when the token is only good for enrolling in two-factor authentication
+	TwoFactorEnrollmentRequired bool `json:"two_factor_enrollment_required,omitempty"`
+}
+
+// issueTokens starts a new session for the device of the request and
+// returns its first refresh token together with a short-lived access token.
+func (app *application) issueTokens(r *http.Request, userID int64) (*AuthTokens, error) {
+
+	plainToken := uuid.New().String()
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	}
+
+	refreshToken := &store.RefreshToken{
+		Token:  hashToken(plainToken),
+		Expiry: time.Now().Add(app.config.auth.token.refreshExpiry),
+	}
+
+	err := app.store.Sessions.Create(r.Context(), session, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(userID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.expiry.Seconds()),
+	}, nil
+}
+
+// generateAccessToken signs an access token of the given session.
+func (app *application) generateAccessToken(userID int64, sessionID string) (string, error) {
+
+	return app.generateToken(userID, app.config.auth.token.expiry, jwt.MapClaims{"sid": sessionID})
+}
+
+// generateToken signs a JWT for the user with the extra claims. Tokens with
+// a purpose claim are refused by AuthTokenMiddleware and only accepted where
+// that purpose is expected.
+func (app *application) generateToken(userID int64, expiry time.Duration, extra jwt.MapClaims) (string, error) {
+
 	// generate the token -> add claims
 	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.expiry).Unix(),
+		"jti": uuid.New().String(),
+		"sub": userID,
+		"exp": time.Now().Add(expiry).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.issuer,
 		"aud": app.config.auth.token.issuer,
 	}
-	token, err := app.authenticator.GenerateToken(claims)
-	if err != nil {
-		app.internalServerError(w, r, err)
+
+	for name, value := range extra {
+		claims[name] = value
 	}
 
-	// send it to the token
+	return app.authenticator.GenerateToken(claims)
+}
+
+// revokeToken denylists the token until it expires on its own.
+func (app *application) revokeToken(ctx context.Context, claims jwt.MapClaims) error {
 
-	// .
-	err = app.jsonResponse(w, http.StatusCreated, token)
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	expiry := time.Now().Add(app.config.auth.token.expiry)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiry = exp.Time
+	}
+
+	return app.denylist().Revoke(ctx, jti, expiry)
+}
+
+func userIDFromClaims(claims jwt.MapClaims) (int64, error) {
+
+	return strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+}
+
+// hashToken returns the sha256 hex digest under which one-time tokens are stored.
+func hashToken(token string) string {
+
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// isLoginLocked reports whether too many failed logins happened recently
+// for the given account or from the given IP address.
+func (app *application) isLoginLocked(ctx context.Context, email, ip string) (bool, error) {
+
+	cfg := app.config.auth.lockout
+	since := time.Now().Add(-cfg.window)
+
+	if cfg.maxAccountAttempts > 0 {
+		failures, err := app.store.LoginAttempts.CountFailuresByEmail(ctx, email, since)
+		if err != nil {
+			return false, err
+		}
+
+		if failures >= cfg.maxAccountAttempts {
+			return true, nil
+		}
+	}
+
+	if cfg.maxIPAttempts > 0 {
+		failures, err := app.store.LoginAttempts.CountFailuresByIP(ctx, ip, since)
+		if err != nil {
+			return false, err
+		}
+
+		if failures >= cfg.maxIPAttempts {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// recordLoginAttempt persists the attempt for the lockout checks and writes
+// it to the audit log. Storage errors are logged and never block the login.
+func (app *application) recordLoginAttempt(ctx context.Context, email, ip string, succeeded bool, reason string) {
+
+	if succeeded {
+		app.logger.Infow("login succeeded", "email", email, "ip", ip)
+	} else {
+		app.logger.Warnw("login failed", "email", email, "ip", ip, "reason", reason)
+	}
+
+	attempt := &store.LoginAttempt{
+		Email:     email,
+		IPAddress: ip,
+		Succeeded: succeeded,
+	}
+
+	err := app.store.LoginAttempts.Create(ctx, attempt)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.logger.Errorw("error recording login attempt", "email", email, "ip", ip, "error", err)
 	}
 }
+
+func getClaimsFromContext(r *http.Request) jwt.MapClaims {
+
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
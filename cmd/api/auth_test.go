@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/high-la/gopher-social/internal/ratelimiter"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestCreateToken(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			lockout: lockoutConfig{
+				maxAccountAttempts: 3,
+				maxIPAttempts:      10,
+				window:             time.Minute * 15,
+			},
+		},
+	}
+
+	newTokenRequest := func(t *testing.T, password string) *http.Request {
+		body := `{"email": "gopher@example.com", "password": "` + password + `"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return req
+	}
+
+	t.Run("should issue a token for valid credentials", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		mux := app.mount()
+
+		rr := executeRequest(newTokenRequest(t, store.MockPassword), mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("should reject an invalid password", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		mux := app.mount()
+
+		rr := executeRequest(newTokenRequest(t, "wrong-password"), mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should lock the account after repeated failures", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		mux := app.mount()
+
+		for i := 0; i < cfg.auth.lockout.maxAccountAttempts; i++ {
+			rr := executeRequest(newTokenRequest(t, "wrong-password"), mux)
+			checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		}
+
+		rr := executeRequest(newTokenRequest(t, store.MockPassword), mux)
+
+		checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should rotate a refresh token", func(t *testing.T) {
+		body := `{"refresh_token": "a-refresh-token"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should require a refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestRefreshTokenTwoFactorRequired(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token:     tokenConfig{expiry: time.Minute},
+			twoFactor: twoFactorConfig{requiredRole: "user"},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{"refresh_token": "a-refresh-token"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data AuthTokens `json:"data"`
+	}
+
+	err = json.NewDecoder(rr.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Data.TwoFactorEnrollmentRequired || res.Data.RefreshToken != "" {
+		t.Errorf("expected an enrollment-only token, got %+v", res.Data)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow unauthenticated requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should not let api keys log out", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+store.APIKeyPrefix+"0123abcd_secret")
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should log out everywhere", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", strings.NewReader(`{"everywhere": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestMagicLink(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+		mail: mailConfig{magicLinkExpiry: time.Minute * 15},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	t.Run("should accept a login link request", func(t *testing.T) {
+		body := `{"email": "gopher@example.com"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/magic-link", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+	})
+
+	t.Run("should stop sending login links to an email requested too often", func(t *testing.T) {
+		app := newTestApplication(t, config{
+			mail: mailConfig{magicLinkExpiry: time.Minute * 15},
+			activationRateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: 2,
+				TimeFrame:            time.Hour,
+				Enabled:              true,
+			},
+		})
+		mux := app.mount()
+
+		var bodies []string
+
+		for i := 0; i < 3; i++ {
+			req, err := http.NewRequest(http.MethodPost, "/v1/authentication/magic-link", strings.NewReader(`{"email": "gopher@example.com"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusAccepted, rr.Code)
+
+			bodies = append(bodies, rr.Body.String())
+		}
+
+		if bodies[2] != bodies[0] {
+			t.Errorf("expected the throttled answer %q to match %q", bodies[2], bodies[0])
+		}
+
+		if links := app.store.Users.(*store.MockUserStore).MagicLinks; links != 2 {
+			t.Errorf("expected 2 login links, got %d", links)
+		}
+	})
+
+	t.Run("should issue tokens for a login link", func(t *testing.T) {
+		body := `{"token": "a-login-token"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/magic-link/consume", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute, refreshExpiry: time.Hour},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	body := `{"email": "gopher@example.com", "password": "` + store.MockPassword + `"}`
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data AuthTokens `json:"data"`
+	}
+
+	err = json.NewDecoder(rr.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.authenticator.ValidateToken(res.Data.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid, _ := token.Claims.(jwt.MapClaims)["sid"].(string)
+	if sid == "" {
+		t.Fatal("expected the access token to carry its session id")
+	}
+
+	newRequest := func(t *testing.T, method, path string) *http.Request {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+res.Data.AccessToken)
+
+		return req
+	}
+
+	t.Run("should list the sessions", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, http.MethodGet, "/v1/users/me/sessions"), mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should not find a session with a malformed id", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, http.MethodDelete, "/v1/users/me/sessions/garbage"), mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should reject the tokens of a revoked session", func(t *testing.T) {
+		rr := executeRequest(newRequest(t, http.MethodDelete, "/v1/users/me/sessions/"+sid), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newRequest(t, http.MethodGet, "/v1/users/me/sessions"), mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
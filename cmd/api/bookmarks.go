@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post in the private bookmarks of the authenticated user
+//	@Tags			bookmarks
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	err := app.store.Bookmarks.Create(r.Context(), user.ID, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the bookmarks of the authenticated user
+//	@Tags			bookmarks
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	err := app.store.Bookmarks.Delete(r.Context(), user.ID, post.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBookmarks godoc
+//
+//	@Summary		Lists bookmarks
+//	@Description	Lists the posts bookmarked by the authenticated user, last saved first by default. Filters work as in the feed
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) listBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Bookmarks.GetByUserID(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setFeedAttachmentURLs(posts)
+
+	err = app.jsonResponse(w, http.StatusOK, posts)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
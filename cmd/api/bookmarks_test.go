@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBookmarks(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path string) *http.Response {
+		req, err := http.NewRequest(method, path, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should bookmark a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/posts/1/bookmark").StatusCode)
+	})
+
+	t.Run("should not remove a missing bookmark", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/bookmark").StatusCode)
+	})
+
+	t.Run("should list bookmarks with the feed filters", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/bookmarks?tags=go&search=gopher").StatusCode)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/users/me/bookmarks?sort=random").StatusCode)
+	})
+
+	t.Run("should flag bookmarked posts", func(t *testing.T) {
+		res := request(t, http.MethodGet, "/v1/posts/1")
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(body), `"bookmarked":false`) {
+			t.Errorf("expected the bookmarked flag in %s", body)
+		}
+	})
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+	// the comment replied to, empty for a comment on the post
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+type CommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type CommentPage struct {
+	Comments []store.Comment `json:"comments"`
+	// pass it back as the cursor query parameter, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CreateComment godoc
+//
+//	@Summary		Comments on a post
+//	@Description	Comments on a post as the authenticated user, or replies to one of its comments when parent_id is set
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload CreateCommentPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	comment := &store.Comment{
+		PostID:  post.ID,
+		UserID:  user.ID,
+		Content: payload.Content,
+		User:    store.User{ID: user.ID, Username: user.Username},
+	}
+
+	ctx := r.Context()
+
+	if payload.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		err = app.checkReplyParent(post, parent)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+	}
+
+	err = app.store.Comments.Create(ctx, comment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusCreated, comment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListComments godoc
+//
+//	@Summary		Lists the comments of a post
+//	@Description	Lists the comments made on the post itself oldest first, their replies are listed by /posts/{id}/comments/{commentID}/replies. Pages are chained with the next_cursor of the previous one
+//	@Tags			comments
+//	@Produce		json
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	CommentPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [get]
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+
+	cq := store.CursorQuery{
+		Limit: 20,
+	}
+
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(cq)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+
+	comments, next, err := app.store.Comments.GetByPostID(r.Context(), post.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, CommentPage{Comments: comments, NextCursor: next})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListReplies godoc
+//
+//	@Summary		Lists the replies to a comment
+//	@Description	Lists the direct replies to a comment oldest first, the comment being possibly a deleted placeholder. Each reply has a reply_count telling whether its own replies are worth fetching
+//	@Tags			comments
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor"
+//	@Success		200			{object}	CommentPage
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID}/replies [get]
+func (app *application) listRepliesHandler(w http.ResponseWriter, r *http.Request) {
+
+	cq := store.CursorQuery{
+		Limit: 20,
+	}
+
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(cq)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+
+	replies, next, err := app.store.Comments.GetReplies(r.Context(), comment.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, CommentPage{Comments: replies, NextCursor: next})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Edits a comment
+//	@Description	Edits a comment. Moderators can edit the comments of others
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Post ID"
+//	@Param			commentID	path		int				true	"Comment ID"
+//	@Param			payload		body		CommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	comment := getCommentFromCtx(r)
+
+	var payload CommentPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	err = app.store.Comments.Update(r.Context(), comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, comment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Moves a comment to the trash, where it can be restored until purged. A comment with replies is listed as a [deleted] placeholder. Admins can delete the comments of others
+//	@Tags			comments
+//	@Param			id			path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{string}	string
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	comment := getCommentFromCtx(r)
+
+	err := app.store.Comments.Delete(r.Context(), comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkReplyParent tells why a comment can not be replied to, if it can't.
+// A nil parent is one that was not found.
+func (app *application) checkReplyParent(post *store.Post, parent *store.Comment) error {
+
+	switch {
+	case parent == nil || parent.PostID != post.ID:
+		return errors.New("parent comment not found")
+	case parent.Deleted:
+		return errors.New("cannot reply to a deleted comment")
+	case parent.Depth+1 > app.config.comments.maxDepth:
+		return fmt.Errorf("replies cannot be nested more than %d levels deep", app.config.comments.maxDepth)
+	}
+
+	return nil
+}
+
+// commentsContextMiddleware loads the comment of the route, which must
+// belong to the post already in the context and not be deleted.
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+
+	return app.commentContext(app.store.Comments.GetByID, next)
+}
+
+// listedCommentContextMiddleware loads the comment of the route like
+// commentsContextMiddleware, deleted comments kept for their replies being
+// loaded as placeholders.
+func (app *application) listedCommentContextMiddleware(next http.Handler) http.Handler {
+
+	return app.commentContext(app.store.Comments.GetListedByID, next)
+}
+
+func (app *application) commentContext(get func(context.Context, int64) (*store.Comment, error), next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := get(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if comment.PostID != getPostFromCtx(r).ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestComments(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+		comments: commentsConfig{maxDepth: 1},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	request := func(t *testing.T, method, path string, userID int64, body string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should comment on a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/posts/1/comments", 2, `{"content": "nice post"}`))
+	})
+
+	t.Run("should reject an empty comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts/1/comments", 2, `{"content": ""}`))
+	})
+
+	t.Run("should reply to a comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/posts/1/comments", 2, `{"content": "agreed", "parent_id": 1}`))
+	})
+
+	t.Run("should not reply to a comment of another post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts/2/comments", 2, `{"content": "agreed", "parent_id": 1}`))
+	})
+
+	t.Run("should not nest replies deeper than allowed", func(t *testing.T) {
+		app.config.comments.maxDepth = 0
+		defer func() { app.config.comments.maxDepth = 1 }()
+
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts/1/comments", 2, `{"content": "agreed", "parent_id": 1}`))
+	})
+
+	t.Run("should list replies", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1/comments/1/replies", 2, ""))
+	})
+
+	t.Run("should list replies under a deleted comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1/comments/99/replies", 2, ""))
+	})
+
+	t.Run("should not edit, delete or react to a deleted comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPatch, "/v1/posts/1/comments/99", 1, `{"content": "edited"}`))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/comments/99", store.MockAdminID, ""))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/posts/1/comments/99/reactions/like", 2, ""))
+	})
+
+	t.Run("should list comments", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1/comments?limit=5", 2, ""))
+	})
+
+	t.Run("should reject a forged cursor", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/posts/1/comments?cursor=not-a-cursor", 2, ""))
+	})
+
+	t.Run("should not find a comment under another post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPatch, "/v1/posts/2/comments/1", 1, `{"content": "edited"}`))
+	})
+
+	t.Run("should let the author edit a comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPatch, "/v1/posts/1/comments/1", 1, `{"content": "edited"}`))
+	})
+
+	t.Run("should forbid others to edit or delete a comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPatch, "/v1/posts/1/comments/1", 2, `{"content": "edited"}`))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/posts/1/comments/1", 2, ""))
+	})
+
+	t.Run("should let admins delete a comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/posts/1/comments/1", store.MockAdminID, ""))
+	})
+}
@@ -20,9 +20,9 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 
 func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
 
-	app.logger.Errorf("conflict response", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.logger.Warnw("conflict response", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	writeJSONError(w, http.StatusConflict, "not found")
+	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -55,6 +55,13 @@ func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
 func (app *application) rateLimiterExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
@@ -63,3 +70,12 @@ func (app *application) rateLimiterExceededResponse(w http.ResponseWriter, r *ht
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
+
+	app.logger.Warnw("login locked", "method", r.Method, "path", r.URL.Path)
+
+	w.Header().Set("Retry-After", retryAfter)
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many failed login attempts, retry after: "+retryAfter)
+}
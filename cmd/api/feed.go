@@ -27,35 +27,84 @@ import (
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 
-	fq := store.PaginatedFeedQuery{
-		Limit:  10,
-		Offset: 0,
-		Sort:   "desc",
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	fq, err := fq.Parse(r)
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.internalServerError(w, r, err)
 		return
 	}
 
-	err = Validate.Struct(fq)
+	app.setFeedAttachmentURLs(feed)
+
+	err = app.jsonResponse(w, http.StatusOK, feed)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+
+}
+
+// ListMentions godoc
+//
+//	@Summary		Lists mentions of the user
+//	@Description	Lists the posts mentioning the authenticated user, filtered like the feed
+//	@Tags			feed
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *application) listMentionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	fq, err := parseFeedQuery(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	ctx := r.Context()
+	user := getUserFromContext(r)
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(204), fq)
+	posts, err := app.store.Posts.GetMentions(r.Context(), user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	err = app.jsonResponse(w, http.StatusOK, feed)
+	app.setFeedAttachmentURLs(posts)
+
+	err = app.jsonResponse(w, http.StatusOK, posts)
 	if err != nil {
 		app.internalServerError(w, r, err)
 	}
+}
+
+// parseFeedQuery reads the pagination and filters of a list of posts.
+func parseFeedQuery(r *http.Request) (store.PaginatedFeedQuery, error) {
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  10,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		return fq, err
+	}
 
+	return fq, Validate.Struct(fq)
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type actorKey string
+
+const actorCtx actorKey = "actor"
+
+// ImpersonateUser godoc
+//
+//	@Summary		Impersonates a user
+//	@Description	Issues a short-lived access token acting as the user, for admins reproducing a bug. The token carries the admin in its act claim, comes without a refresh token and its writes are recorded in the audit log
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path		int			true	"User ID"
+//	@Success		201	{object}	AuthTokens	"Impersonation token"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/impersonate [post]
+func (app *application) impersonateUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	actor := getUserFromContext(r)
+	ctx := r.Context()
+
+	allowed, err := app.checkRolePrecedence(ctx, actor, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if userID == actor.ID {
+		app.badRequestResponse(w, r, errors.New("cannot impersonate yourself"))
+		return
+	}
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// admins can not borrow the rights of their peers
+	if user.Role.Level >= actor.Role.Level {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	expiry := app.config.auth.token.impersonationExpiry
+
+	token, err := app.generateToken(user.ID, expiry, jwt.MapClaims{"act": map[string]any{"sub": actor.ID}})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// no token is handed out unless its use can be traced back
+	err = app.store.AuditLogs.Create(ctx, &store.AuditLog{
+		ActorID:   actor.ID,
+		UserID:    user.ID,
+		Action:    store.AuditActionImpersonate,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    http.StatusCreated,
+		IPAddress: clientIP(r),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("impersonation started", "actor_id", actor.ID, "user_id", user.ID, "ip", clientIP(r))
+
+	err = app.jsonResponse(w, http.StatusCreated, AuthTokens{
+		AccessToken: token,
+		ExpiresIn:   int64(expiry.Seconds()),
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// authenticateActor loads the admin behind an impersonation token. It
+// returns nil for regular tokens, and an error when the token is no longer
+// good: the actor lost the admin role or logged out everywhere.
+func (app *application) authenticateActor(r *http.Request, claims jwt.MapClaims) (*store.User, error) {
+
+	act, ok := claims["act"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	actorID, err := strconv.ParseInt(fmt.Sprintf("%.f", act["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+
+	revoked, err := app.isTokenRevoked(ctx, claims, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("actor tokens have been revoked")
+	}
+
+	actor, err := app.getUser(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := app.checkRolePrecedence(ctx, actor, "admin")
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, errors.New("actor is no longer an admin")
+	}
+
+	return actor, nil
+}
+
+// serveImpersonated serves a request made with an impersonation token,
+// recording in the audit log the ones that may change something. The entry
+// is written first, a write that cannot be audited is not served.
+func (app *application) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler) {
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	actor := getActorFromContext(r)
+	user := getUserFromContext(r)
+
+	entry := &store.AuditLog{
+		ActorID:   actor.ID,
+		UserID:    user.ID,
+		Action:    store.AuditActionImpersonatedRequest,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		IPAddress: clientIP(r),
+	}
+
+	err := app.store.AuditLogs.Create(r.Context(), entry)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	next.ServeHTTP(ww, r)
+
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// a client that hung up does not keep the status out of the log
+	err = app.store.AuditLogs.UpdateStatus(context.WithoutCancel(r.Context()), entry.ID, status)
+	if err != nil {
+		app.logger.Errorw("error recording impersonated request status", "audit_log_id", entry.ID, "status", status, "error", err)
+	}
+}
+
+// getActorFromContext returns the admin impersonating the user of the
+// request, or nil when the user is acting for themselves.
+func getActorFromContext(r *http.Request) *store.User {
+
+	actor, _ := r.Context().Value(actorCtx).(*store.User)
+	return actor
+}
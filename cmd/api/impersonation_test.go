@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestImpersonation(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute, impersonationExpiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	auditLogs := app.store.AuditLogs.(*store.MockAuditLogStore)
+
+	request := func(t *testing.T, method, path, token string) int {
+		req, err := http.NewRequest(method, path, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	adminToken, err := app.generateToken(store.MockAdminID, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should forbid users that are not admins", func(t *testing.T) {
+		userToken, err := app.generateToken(1, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/users/2/impersonate", userToken))
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/users/2/impersonate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data AuthTokens `json:"data"`
+	}
+
+	err = json.NewDecoder(rr.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := res.Data.AccessToken
+
+	if len(auditLogs.Logs) != 1 || auditLogs.Logs[0].Action != store.AuditActionImpersonate {
+		t.Fatalf("expected the impersonation to be audited, got %+v", auditLogs.Logs)
+	}
+
+	t.Run("should act as the user without auditing reads", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/2", token))
+
+		if len(auditLogs.Logs) != 1 {
+			t.Errorf("expected reads not to be audited, got %+v", auditLogs.Logs)
+		}
+	})
+
+	t.Run("should not manage the user credentials", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/users/me/sessions", token))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/users/3/impersonate", token))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/authentication/logout", token))
+	})
+
+	t.Run("should audit writes", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/posts/1/bookmark", token))
+
+		last := auditLogs.Logs[len(auditLogs.Logs)-1]
+		if last.Action != store.AuditActionImpersonatedRequest || last.ActorID != store.MockAdminID || last.UserID != 2 || last.Status != http.StatusNoContent {
+			t.Errorf("unexpected audit log %+v", last)
+		}
+	})
+
+	t.Run("should not serve writes that cannot be audited", func(t *testing.T) {
+		auditLogs.Err = errors.New("audit log unavailable")
+		defer func() { auditLogs.Err = nil }()
+
+		checkResponseCode(t, http.StatusInternalServerError, request(t, http.MethodPut, "/v1/posts/1/bookmark", token))
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/high-la/gopher-social/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys to verify the issued tokens. Empty when tokens are signed with a shared secret
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+
+	set := auth.JWKS{Keys: []auth.JWK{}}
+
+	if provider, ok := app.authenticator.(auth.JWKSProvider); ok {
+		set = provider.JWKS()
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	// served without the data envelope, as expected by JWKS consumers
+	err := writeJSON(w, http.StatusOK, set)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
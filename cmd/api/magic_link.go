@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/mailer"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type MagicLinkPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ConsumeMagicLinkPayload struct {
+	Token string `json:"token" validate:"required,max=255"`
+}
+
+// requestMagicLinkHandler godoc
+//
+//	@Summary		Requests a login link
+//	@Description	Emails a one-time, short-lived passwordless login link. The response is the same whether the email is known or not, and when links are requested too often for the email
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MagicLinkPayload	true	"User email"
+//	@Success		202		{string}	string				"Login link requested"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link [post]
+func (app *application) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload MagicLinkPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the activation limiter is shared, the prefix gives login links their own budget
+	if app.config.activationRateLimiter.Enabled {
+		if allow, _ := app.activationRateLimiter.Allow("magic-link:" + strings.ToLower(payload.Email)); !allow {
+			app.logger.Infow("login link throttled", "email", payload.Email, "ip", clientIP(r))
+			app.magicLinkRequestedResponse(w, r)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	switch err {
+	case nil:
+		err = app.sendMagicLink(r, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	case store.ErrNotFound:
+		app.logger.Infow("login link requested for unknown email", "email", payload.Email, "ip", clientIP(r))
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.magicLinkRequestedResponse(w, r)
+}
+
+// magicLinkRequestedResponse answers every login link request the same way,
+// whether the email is unknown or throttled.
+func (app *application) magicLinkRequestedResponse(w http.ResponseWriter, r *http.Request) {
+
+	err := app.jsonResponse(w, http.StatusAccepted, "if the email belongs to an account, a login link has been sent")
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// sendMagicLink stores a new login token for the user, invalidating the
+// previous one, and emails it in the background.
+func (app *application) sendMagicLink(r *http.Request, user *store.User) error {
+
+	plainToken := uuid.New().String()
+
+	err := app.store.Users.CreateMagicLink(r.Context(), user.ID, hashToken(plainToken), app.config.mail.magicLinkExpiry)
+	if err != nil {
+		return err
+	}
+
+	loginURL := fmt.Sprintf("%s/magic-link/%s", app.config.frontendURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username string
+		LoginURL string
+		Expiry   string
+	}{
+		Username: user.Username,
+		LoginURL: loginURL,
+		Expiry:   app.config.mail.magicLinkExpiry.String(),
+	}
+
+	go func() {
+		status, err := app.mailer.Send(mailer.MagicLinkTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending login link email", "user_id", user.ID, "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status)
+	}()
+
+	app.logger.Infow("login link requested", "user_id", user.ID, "ip", clientIP(r))
+
+	return nil
+}
+
+// consumeMagicLinkHandler godoc
+//
+//	@Summary		Logs in with a login link
+//	@Description	Spends a login link token and creates an access and refresh token pair. Users with two-factor authentication get a challenge token instead
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ConsumeMagicLinkPayload	true	"Login link token"
+//	@Success		201		{object}	AuthTokens				"Tokens"
+//	@Success		202		{object}	TwoFactorChallenge		"Two-factor code required"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link/consume [post]
+func (app *application) consumeMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload ConsumeMagicLinkPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.ConsumeMagicLink(r.Context(), payload.Token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid or expired login link"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.loginResponse(w, r, user)
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"expvar"
+	"fmt"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/high-la/gopher-social/internal/auth"
 	"github.com/high-la/gopher-social/internal/db"
 	"github.com/high-la/gopher-social/internal/env"
 	"github.com/high-la/gopher-social/internal/mailer"
+	"github.com/high-la/gopher-social/internal/oidc"
 	"github.com/high-la/gopher-social/internal/ratelimiter"
+	"github.com/high-la/gopher-social/internal/storage"
 	"github.com/high-la/gopher-social/internal/store"
 	"github.com/high-la/gopher-social/internal/store/cache"
 	"github.com/joho/godotenv"
@@ -70,14 +74,51 @@ func main() {
 	// Email
 	fromEmail := env.GetString("GOPHER_SOCIAL_FROM_EMAIL", "")
 	sendGridApiKey := env.GetString("GOPHER_SOCIAL_SENDGRID_API_KEY", "")
+	passwordResetExpiry := env.GetTime("GOPHER_SOCIAL_PASSWORD_RESET_EXPIRY", time.Hour)
+	magicLinkExpiry := env.GetTime("GOPHER_SOCIAL_MAGIC_LINK_EXPIRY", 15*time.Minute)
 	// Basic Auth
 	basicAuthUsername := env.GetString("GOPHER_SOCIAL_BASIC_AUTH_USERNAME", "")
 	basicAuthPassword := env.GetString("GOPHER_SOCIAL_BASIC_AUTH_PASSWORD", "")
 	// Auth token
 	authTokenSecret := env.GetString("GOPHER_SOCIAL_AUTH_TOKEN_SECRET", "")
+	authTokenExpiry := env.GetTime("GOPHER_SOCIAL_AUTH_TOKEN_EXPIRY", 15*time.Minute)
+	refreshTokenExpiry := env.GetTime("GOPHER_SOCIAL_AUTH_REFRESH_TOKEN_EXPIRY", 30*24*time.Hour)
+	impersonationTokenExpiry := env.GetTime("GOPHER_SOCIAL_AUTH_IMPERSONATION_TOKEN_EXPIRY", 15*time.Minute)
+	signingKeyFile := env.GetString("GOPHER_SOCIAL_AUTH_TOKEN_SIGNING_KEY_FILE", "")
+	verificationKeyFiles := env.GetString("GOPHER_SOCIAL_AUTH_TOKEN_VERIFICATION_KEY_FILES", "")
+	// Login lockout
+	lockoutAccountAttempts := env.GetInt("GOPHER_SOCIAL_AUTH_LOCKOUT_ACCOUNT_ATTEMPTS", 5)
+	lockoutIPAttempts := env.GetInt("GOPHER_SOCIAL_AUTH_LOCKOUT_IP_ATTEMPTS", 20)
+	lockoutWindow := env.GetTime("GOPHER_SOCIAL_AUTH_LOCKOUT_WINDOW", 15*time.Minute)
+	// Two-factor authentication
+	twoFactorIssuer := env.GetString("GOPHER_SOCIAL_AUTH_2FA_ISSUER", "GopherSocial")
+	twoFactorChallengeExpiry := env.GetTime("GOPHER_SOCIAL_AUTH_2FA_CHALLENGE_EXPIRY", 5*time.Minute)
+	twoFactorRequiredRole := env.GetString("GOPHER_SOCIAL_AUTH_2FA_REQUIRED_ROLE", "")
+	// Social login, e.g. "google" configured by GOPHER_SOCIAL_OIDC_GOOGLE_ISSUER,
+	// GOPHER_SOCIAL_OIDC_GOOGLE_CLIENT_ID and GOPHER_SOCIAL_OIDC_GOOGLE_CLIENT_SECRET
+	oidcProviders := env.GetString("GOPHER_SOCIAL_OIDC_PROVIDERS", "")
+	oidcStateExpiry := env.GetTime("GOPHER_SOCIAL_OIDC_STATE_EXPIRY", 10*time.Minute)
+	// Comments
+	commentsMaxDepth := env.GetInt("GOPHER_SOCIAL_COMMENTS_MAX_DEPTH", 5)
+	// Posts
+	postsPublishInterval := env.GetTime("GOPHER_SOCIAL_POSTS_PUBLISH_INTERVAL", time.Minute)
+	// Trash, purged on the sweeper interval
+	trashRetention := env.GetTime("GOPHER_SOCIAL_TRASH_RETENTION", 30*24*time.Hour)
+	// Media, kept on the local filesystem or in an S3-compatible store
+	mediaBackend := env.GetString("GOPHER_SOCIAL_MEDIA_BACKEND", "local")
+	mediaDir := env.GetString("GOPHER_SOCIAL_MEDIA_DIR", "./media")
+	mediaMaxUploadSize := env.GetInt("GOPHER_SOCIAL_MEDIA_MAX_UPLOAD_SIZE", 10<<20)
+	mediaMaxAttachments := env.GetInt("GOPHER_SOCIAL_MEDIA_MAX_ATTACHMENTS", 4)
+	mediaThumbnailSize := env.GetInt("GOPHER_SOCIAL_MEDIA_THUMBNAIL_SIZE", 320)
+	mediaMaxConcurrentProcessing := env.GetInt("GOPHER_SOCIAL_MEDIA_MAX_CONCURRENT_PROCESSING", 2)
 	// Rate limiter
 	reqPerTimeFrame := env.GetInt("GOPHER_SOCIAL_RATELIMITER_REQUEST_COUNT", 20)
 	isRateLimiterEnabled := env.GetBool("GOPHER_SOCIAL_RATELIMITER_ENABLED", true)
+	activationResendLimit := env.GetInt("GOPHER_SOCIAL_ACTIVATION_RESEND_LIMIT", 3)
+	activationResendWindow := env.GetTime("GOPHER_SOCIAL_ACTIVATION_RESEND_WINDOW", time.Hour)
+	// Sweeper
+	sweeperInterval := env.GetTime("GOPHER_SOCIAL_SWEEPER_INTERVAL", time.Hour)
+	unactivatedGracePeriod := env.GetTime("GOPHER_SOCIAL_UNACTIVATED_USER_GRACE_PERIOD", 30*24*time.Hour)
 
 	cfg := config{
 		addr:        addr,
@@ -97,8 +138,10 @@ func main() {
 		},
 		env: appEnv,
 		mail: mailConfig{
-			expiry:    time.Hour * 24 * 3,
-			fromEmail: fromEmail,
+			expiry:              time.Hour * 24 * 3,
+			passwordResetExpiry: passwordResetExpiry,
+			magicLinkExpiry:     magicLinkExpiry,
+			fromEmail:           fromEmail,
 			sendGrid: sendGridConfig{
 				apiKey: sendGridApiKey,
 			},
@@ -109,9 +152,25 @@ func main() {
 				password: basicAuthPassword,
 			},
 			token: tokenConfig{
-				secret: authTokenSecret,
-				expiry: time.Hour * 24 * 3,
-				issuer: "gophersocial",
+				secret:        authTokenSecret,
+				expiry:        authTokenExpiry,
+				refreshExpiry: refreshTokenExpiry,
+				issuer:        "gophersocial",
+
+				impersonationExpiry: impersonationTokenExpiry,
+
+				signingKeyFile:       signingKeyFile,
+				verificationKeyFiles: splitList(verificationKeyFiles),
+			},
+			lockout: lockoutConfig{
+				maxAccountAttempts: lockoutAccountAttempts,
+				maxIPAttempts:      lockoutIPAttempts,
+				window:             lockoutWindow,
+			},
+			twoFactor: twoFactorConfig{
+				issuer:          twoFactorIssuer,
+				challengeExpiry: twoFactorChallengeExpiry,
+				requiredRole:    twoFactorRequiredRole,
 			},
 		},
 		rateLimiter: ratelimiter.Config{
@@ -119,6 +178,44 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              isRateLimiterEnabled,
 		},
+		activationRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: activationResendLimit,
+			TimeFrame:            activationResendWindow,
+			Enabled:              true,
+		},
+		sweeper: sweeperConfig{
+			interval:               sweeperInterval,
+			unactivatedGracePeriod: unactivatedGracePeriod,
+		},
+		oidc: oidcConfig{
+			providers:   oidcProvidersFromEnv(splitList(oidcProviders)),
+			stateExpiry: oidcStateExpiry,
+		},
+		comments: commentsConfig{
+			maxDepth: commentsMaxDepth,
+		},
+		posts: postsConfig{
+			publishInterval: postsPublishInterval,
+		},
+		trash: trashConfig{
+			retention: trashRetention,
+		},
+		media: mediaConfig{
+			backend: mediaBackend,
+			dir:     mediaDir,
+			s3: storage.S3Config{
+				Endpoint:  env.GetString("GOPHER_SOCIAL_MEDIA_S3_ENDPOINT", ""),
+				Region:    env.GetString("GOPHER_SOCIAL_MEDIA_S3_REGION", "us-east-1"),
+				Bucket:    env.GetString("GOPHER_SOCIAL_MEDIA_S3_BUCKET", ""),
+				AccessKey: env.GetString("GOPHER_SOCIAL_MEDIA_S3_ACCESS_KEY", ""),
+				SecretKey: env.GetString("GOPHER_SOCIAL_MEDIA_S3_SECRET_KEY", ""),
+				PublicURL: env.GetString("GOPHER_SOCIAL_MEDIA_S3_PUBLIC_URL", ""),
+			},
+			maxUploadSize:           int64(mediaMaxUploadSize),
+			maxAttachments:          mediaMaxAttachments,
+			thumbnailSize:           mediaThumbnailSize,
+			maxConcurrentProcessing: mediaMaxConcurrentProcessing,
+		},
 	}
 
 	// Database
@@ -144,22 +241,67 @@ func main() {
 	mailer := mailer.NewSendGrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
 	// Auth
-	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.issuer, cfg.auth.token.issuer)
+	var authenticator auth.Authenticator = auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.issuer, cfg.auth.token.issuer)
+
+	if cfg.auth.token.signingKeyFile != "" {
+		authenticator, err = auth.NewAsymmetricJWTAuthenticator(
+			cfg.auth.token.signingKeyFile,
+			cfg.auth.token.verificationKeyFiles,
+			cfg.auth.token.issuer,
+			cfg.auth.token.issuer,
+		)
+		if err != nil {
+			logger.Fatal("unable to load the token signing keys \n", err)
+		}
+		logger.Info("asymmetric token signing enabled")
+	}
+
+	// Social login
+	providers := map[string]*oidc.Provider{}
+	for _, p := range cfg.oidc.providers {
+		redirectURL := fmt.Sprintf("%s/v1/authentication/oidc/%s/callback", cfg.apiURL, p.name)
+		providers[p.name] = oidc.NewProvider(p.name, p.issuer, p.clientID, p.clientSecret, redirectURL)
+		logger.Infow("oidc provider configured", "provider", p.name, "issuer", p.issuer)
+	}
+
+	// Media
+	var blobs storage.Blobs
+	switch cfg.media.backend {
+	case "s3":
+		blobs = storage.NewS3(cfg.media.s3)
+		logger.Infow("s3 media storage configured", "endpoint", cfg.media.s3.Endpoint, "bucket", cfg.media.s3.Bucket)
+	case "local":
+		blobs, err = storage.NewLocal(cfg.media.dir, cfg.apiURL+"/v1/media")
+		if err != nil {
+			logger.Fatal("unable to create the media directory \n", err)
+		}
+	default:
+		logger.Fatalf("unknown media backend %q", cfg.media.backend)
+	}
 
 	// Rate limiter
+	activationRateLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.activationRateLimiter.RequestsPerTimeFrame,
+		cfg.activationRateLimiter.TimeFrame,
+	)
+
 	ratelimiter := ratelimiter.NewFixedWindowLimiter(
 		cfg.rateLimiter.RequestsPerTimeFrame,
 		cfg.rateLimiter.TimeFrame,
 	)
 
 	app := &application{
-		config:        cfg,
-		store:         store,
-		cacheStorage:  cacheStorage,
-		logger:        logger,
-		mailer:        mailer,
-		authenticator: jwtAuthenticator,
-		rateLimiter:   ratelimiter,
+		config:                cfg,
+		store:                 store,
+		cacheStorage:          cacheStorage,
+		logger:                logger,
+		mailer:                mailer,
+		authenticator:         authenticator,
+		rateLimiter:           ratelimiter,
+		activationRateLimiter: activationRateLimiter,
+		oidcProviders:         providers,
+		blobs:                 blobs,
+		mediaSlots:            make(chan struct{}, max(1, cfg.media.maxConcurrentProcessing)),
 	}
 
 	//  Metrics collected
@@ -175,3 +317,37 @@ func main() {
 
 	logger.Fatal(app.run(mux))
 }
+
+// splitList splits a comma separated env value, dropping empty items.
+func splitList(val string) []string {
+
+	items := []string{}
+
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// oidcProvidersFromEnv reads the settings of each named provider.
+func oidcProvidersFromEnv(names []string) []oidcProviderConfig {
+
+	providers := []oidcProviderConfig{}
+
+	for _, name := range names {
+		prefix := "GOPHER_SOCIAL_OIDC_" + strings.ToUpper(name)
+
+		providers = append(providers, oidcProviderConfig{
+			name:         name,
+			issuer:       env.GetString(prefix+"_ISSUER", ""),
+			clientID:     env.GetString(prefix+"_CLIENT_ID", ""),
+			clientSecret: env.GetString(prefix+"_CLIENT_SECRET", ""),
+		})
+	}
+
+	return providers
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/high-la/gopher-social/internal/store"
@@ -14,6 +16,20 @@ import (
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
+	return app.authenticate(next, "")
+}
+
+// TwoFactorEnrollmentMiddleware also accepts the tokens issued to users who
+// must enroll in two-factor authentication before logging in.
+func (app *application) TwoFactorEnrollmentMiddleware(next http.Handler) http.Handler {
+
+	return app.authenticate(next, purposeTwoFactorEnrollment)
+}
+
+// authenticate serves requests bearing an API key, an access token or a
+// token issued for the allowed purpose.
+func (app *application) authenticate(next http.Handler, allowedPurpose string) http.Handler {
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		authHeader := r.Header.Get("Authorization")
@@ -30,6 +46,12 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		token := parts[1]
 
+		// personal access tokens are not JWTs
+		if strings.HasPrefix(token, store.APIKeyPrefix) {
+			app.serveWithAPIKey(w, r, next, token)
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -38,7 +60,13 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		claims, _ := jwtToken.Claims.(jwt.MapClaims)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		purpose, _ := claims["purpose"].(string)
+		if purpose != "" && purpose != allowedPurpose {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token issued for %q", purpose))
+			return
+		}
+
+		userID, err := userIDFromClaims(claims)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
@@ -46,17 +74,133 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
+		revoked, err := app.isTokenRevoked(ctx, claims, userID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token has been revoked"))
+			return
+		}
+
+		// tokens of a revoked session are rejected before they expire
+		if sid, _ := claims["sid"].(string); sid != "" {
+			revoked, err = app.store.Sessions.Touch(ctx, sid, clientIP(r))
+			if err != nil && err != store.ErrNotFound {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if revoked || err == store.ErrNotFound {
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("session has been revoked"))
+				return
+			}
+		}
+
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
+		actor, err := app.authenticateActor(r, claims)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
+
+		if actor != nil {
+			ctx = context.WithValue(ctx, actorCtx, actor)
+			app.serveImpersonated(w, r.WithContext(ctx), next)
+			return
+		}
+
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func (app *application) serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+
+	ctx := r.Context()
+
+	key, err := app.store.APIKeys.GetByToken(ctx, token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid or expired api key"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(ctx, key.UserID)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.store.APIKeys.Touch(ctx, key.ID)
+	if err != nil {
+		app.logger.Errorw("error recording api key usage", "api_key_id", key.ID, "error", err)
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	ctx = context.WithValue(ctx, apiKeyCtx, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// ScopesMiddleware restricts API key requests to the keys holding the read
+// scope for safe methods and the write scope for the others. Requests
+// authenticated with a JWT are let through.
+func (app *application) ScopesMiddleware(readScope, writeScope string) func(http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			key := getAPIKeyFromContext(r)
+			if key == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+
+			if !slices.Contains(key.Scopes, scope) {
+				app.logger.Warnw("api key missing scope", "api_key_id", key.ID, "scope", scope)
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SessionOnlyMiddleware refuses API keys and impersonation tokens, for the
+// routes that manage the account credentials themselves.
+func (app *application) SessionOnlyMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if getAPIKeyFromContext(r) != nil || getActorFromContext(r) != nil {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 
 	if !app.config.redisCfg.enabled {
@@ -89,6 +233,57 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, nil
 }
 
+// tokenDenylist is implemented by both the redis and the postgres store of
+// revoked tokens.
+type tokenDenylist interface {
+	Revoke(context.Context, string, time.Time) error
+	IsRevoked(context.Context, string) (bool, error)
+	RevokeUser(context.Context, int64, time.Time) error
+	UserRevokedAt(context.Context, int64) (time.Time, error)
+}
+
+func (app *application) denylist() tokenDenylist {
+
+	if app.config.redisCfg.enabled {
+		return app.cacheStorage.RevokedTokens
+	}
+
+	return app.store.RevokedTokens
+}
+
+func (app *application) isTokenRevoked(ctx context.Context, claims jwt.MapClaims, userID int64) (bool, error) {
+
+	jti, _ := claims["jti"].(string)
+	if jti != "" {
+		revoked, err := app.denylist().IsRevoked(ctx, jti)
+		if err != nil {
+			return false, err
+		}
+
+		if revoked {
+			return true, nil
+		}
+	}
+
+	revokedAt, err := app.denylist().UserRevokedAt(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if revokedAt.IsZero() {
+		return false, nil
+	}
+
+	// iat has a one second resolution, tokens issued during the second of
+	// the revocation are kept so that a fresh login is not rejected
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return true, nil
+	}
+
+	return issuedAt.Unix() < revokedAt.Unix(), nil
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
@@ -161,6 +356,35 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
+func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		user := getUserFromContext(r)
+		comment := getCommentFromCtx(r)
+
+		// if it is the user's comment
+		if comment.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// role precedence check
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 
 	role, err := app.store.Roles.GetByName(ctx, roleName)
@@ -189,3 +413,15 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientIP returns the client address without its port. RemoteAddr has
+// already been rewritten by middleware.RealIP when a proxy header is present.
+func clientIP(r *http.Request) string {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
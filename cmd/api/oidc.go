@@ -0,0 +1,444 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/oidc"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type AuthorizationURL struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts a social login
+//	@Description	Redirects to the OpenID provider. The provider sends the user back to the callback endpoint
+//	@Tags			authentication
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		302			{string}	string	"Redirect to the provider"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+
+	provider, err := app.getOIDCProvider(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	authURL, err := app.startOIDCFlow(w, r, provider, nil)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes a social login
+//	@Description	Verifies the ID token of the provider and logs in the linked user. The browser must present the cookie set when the flow started. Unknown users are registered, and activated right away when the provider verified their email. Completes a link started from /users/me/identities as well
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			code		query		string				true	"Authorization code"
+//	@Param			state		query		string				true	"State"
+//	@Success		201			{object}	AuthTokens			"Tokens"
+//	@Success		202			{object}	TwoFactorChallenge	"Two-factor code required"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+
+	provider, err := app.getOIDCProvider(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.badRequestResponse(w, r, fmt.Errorf("provider returned an error: %s", providerErr))
+		return
+	}
+
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		app.badRequestResponse(w, r, fmt.Errorf("code and state are required"))
+		return
+	}
+
+	ctx := r.Context()
+
+	// 01. the state proves the flow was started here, and only once
+	pending, err := app.store.Identities.ConsumeState(ctx, state)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("invalid or expired state"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the browser completing the flow must be the one that started it
+	clearOIDCBindingCookie(w)
+
+	binding, err := r.Cookie(oidcBindingCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hashToken(binding.Value)), []byte(pending.Binding)) != 1 {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("state issued to another client"))
+		return
+	}
+
+	if pending.Provider != provider.Name {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("state issued for provider %q", pending.Provider))
+		return
+	}
+
+	// 02. verify the ID token and that it answers our request
+	claims, err := provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if claims.Nonce != pending.Nonce {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("%w: nonce mismatch", oidc.ErrInvalidIDToken))
+		return
+	}
+
+	identity := &store.Identity{
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	// 03. linking to the account that started the flow
+	if pending.UserID != nil {
+		identity.UserID = *pending.UserID
+
+		err = app.store.Identities.Link(ctx, identity)
+		if err != nil {
+			switch err {
+			case store.ErrConflict:
+				app.conflictResponse(w, r, fmt.Errorf("this %s account or provider is already linked", provider.Name))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.logger.Infow("identity linked", "user_id", identity.UserID, "provider", provider.Name)
+
+		err = app.jsonResponse(w, http.StatusCreated, identity)
+		if err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// 04. logging in, or registering
+	user, err := app.store.Identities.GetUser(ctx, provider.Name, claims.Subject)
+	switch err {
+	case nil:
+		app.loginResponse(w, r, user)
+	case store.ErrNotFound:
+		app.registerFromIdentity(w, r, claims, identity)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// registerFromIdentity creates the account of a first social login. The
+// email is trusted only when the provider asserts it was verified, other
+// accounts go through the usual activation email.
+func (app *application) registerFromIdentity(w http.ResponseWriter, r *http.Request, claims *oidc.Claims, identity *store.Identity) {
+
+	if claims.Email == "" {
+		app.badRequestResponse(w, r, fmt.Errorf("the provider did not share an email address"))
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, "user")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := &store.User{
+		Email:    claims.Email,
+		Role:     *role,
+		IsActive: claims.EmailVerified,
+	}
+
+	// the account has no usable password until one is reset
+	err = user.Password.Set(uuid.New().String())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	base := oidcUsername(claims)
+
+	for attempt := 0; ; attempt++ {
+		user.Username = base
+		if attempt > 0 {
+			user.Username = fmt.Sprintf("%s_%s", base, uuid.New().String()[:5])
+		}
+
+		err = app.store.Identities.CreateUser(ctx, user, identity)
+		if err != store.ErrDuplicateUsername || attempt == 3 {
+			break
+		}
+	}
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			// linking by email would let a provider take over an account
+			app.conflictResponse(w, r, fmt.Errorf("an account already uses this email, log in and link the provider instead"))
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("user registered from identity", "user_id", user.ID, "provider", identity.Provider, "active", user.IsActive)
+
+	if user.IsActive {
+		app.loginResponse(w, r, user)
+		return
+	}
+
+	err = app.resendActivation(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusAccepted, "account created, check your email to activate it")
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListIdentities godoc
+//
+//	@Summary		Lists linked providers
+//	@Description	Lists the OpenID provider accounts linked to the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Identity
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/identities [get]
+func (app *application) listIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+
+	identities, err := app.store.Identities.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, identities)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// LinkIdentity godoc
+//
+//	@Summary		Starts linking a provider
+//	@Description	Returns the provider URL to send the user to and sets the cookie the callback expects from the same browser. The link is completed by the callback endpoint
+//	@Tags			users
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		200			{object}	AuthorizationURL
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/identities/{provider} [post]
+func (app *application) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+
+	provider, err := app.getOIDCProvider(r)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	authURL, err := app.startOIDCFlow(w, r, provider, &user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, AuthorizationURL{AuthorizationURL: authURL})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnlinkIdentity godoc
+//
+//	@Summary		Unlinks a provider
+//	@Description	Removes the link between the authenticated user and a provider account
+//	@Tags			users
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		204			{string}	string
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/identities/{provider} [delete]
+func (app *application) unlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	provider := chi.URLParam(r, "provider")
+
+	err := app.store.Identities.Unlink(r.Context(), user.ID, provider)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("identity unlinked", "user_id", user.ID, "provider", provider)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// oidcBindingCookie ties a pending authorization request to the browser
+// that started it, the callback refuses a state without it.
+const oidcBindingCookie = "oidc_binding"
+
+// startOIDCFlow stores a pending authorization request, sets the binding
+// cookie and returns the provider URL. userID is set when linking a provider
+// to an account.
+func (app *application) startOIDCFlow(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID *int64) (string, error) {
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	binding, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	pending := &store.OIDCState{
+		State:        hashToken(state),
+		Binding:      hashToken(binding),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		Expiry:       time.Now().Add(app.config.oidc.stateExpiry),
+	}
+
+	err = app.store.Identities.CreateState(r.Context(), pending)
+	if err != nil {
+		return "", err
+	}
+
+	// Lax still sends the cookie on the top-level redirect back from the provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    binding,
+		Path:     "/v1/authentication/oidc",
+		MaxAge:   int(app.config.oidc.stateExpiry.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+}
+
+func clearOIDCBindingCookie(w http.ResponseWriter) {
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Path:     "/v1/authentication/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (app *application) getOIDCProvider(r *http.Request) (*oidc.Provider, error) {
+
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := app.oidcProviders[name]
+	if !ok {
+		return nil, errors.New("unknown provider " + name)
+	}
+
+	return provider, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// oidcUsername derives a username from the preferred username of the
+// provider account, or else from its email.
+func oidcUsername(claims *oidc.Claims) string {
+
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	name = usernameInvalidChars.ReplaceAllString(name, "")
+	if name == "" {
+		name = "gopher"
+	}
+
+	// leave room for a suffix within the 20 characters of a username
+	if len(name) > 14 {
+		name = name[:14]
+	}
+
+	return name
+}
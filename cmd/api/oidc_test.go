@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/oidc"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	issuer, err := oidc.NewMockIssuer("client-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer issuer.Close()
+
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+		oidc: oidcConfig{stateExpiry: time.Minute},
+	}
+
+	app := newTestApplication(t, cfg)
+	app.oidcProviders = map[string]*oidc.Provider{
+		"mock": oidc.NewProvider("mock", issuer.URL, "client-id", "client-secret", "http://localhost/v1/authentication/oidc/mock/callback"),
+	}
+	mux := app.mount()
+
+	t.Run("should not know other providers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/unknown/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	// startLogin plays the browser up to the redirect back from the provider
+	startLogin := func(t *testing.T) (string, *http.Cookie) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/mock/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusFound, rr.Code)
+
+		authURL := rr.Header().Get("Location")
+
+		code, err := issuer.Authorize(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		callback := "/v1/authentication/oidc/mock/callback?" + url.Values{
+			"code":  {code},
+			"state": {u.Query().Get("state")},
+		}.Encode()
+
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != oidcBindingCookie || !cookies[0].HttpOnly {
+			t.Fatalf("expected the binding cookie, got %+v", cookies)
+		}
+
+		return callback, cookies[0]
+	}
+
+	t.Run("should refuse a callback from another browser", func(t *testing.T) {
+		callback, _ := startLogin(t)
+
+		req, err := http.NewRequest(http.MethodGet, callback, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(&http.Cookie{Name: oidcBindingCookie, Value: "another-browser"})
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	callback, binding := startLogin(t)
+
+	t.Run("should register and log in a verified user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, callback, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(binding)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("should not accept the same state twice", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, callback, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(binding)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
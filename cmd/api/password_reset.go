@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/mailer"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=4,max=16"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a one-time password reset link. The response is the same whether the email is known or not
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload ForgotPasswordPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the activation limiter is shared, the prefix gives reset emails their own budget
+	if app.config.activationRateLimiter.Enabled {
+		if allow, retryAfter := app.activationRateLimiter.Allow("password-reset:" + strings.ToLower(payload.Email)); !allow {
+			app.rateLimiterExceededResponse(w, r, retryAfter.String())
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	switch err {
+	case nil:
+		err = app.sendPasswordReset(r, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	case store.ErrNotFound:
+		app.logger.Infow("password reset requested for unknown email", "email", payload.Email, "ip", clientIP(r))
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// unknown emails get the exact same answer
+	err = app.jsonResponse(w, http.StatusAccepted, "if the email belongs to an account, a reset link has been sent")
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// sendPasswordReset stores a new reset token for the user and emails it.
+// The email is sent in the background so the response time does not reveal
+// whether the account exists.
+func (app *application) sendPasswordReset(r *http.Request, user *store.User) error {
+
+	plainToken := uuid.New().String()
+
+	err := app.store.Users.CreatePasswordReset(r.Context(), user.ID, hashToken(plainToken), app.config.mail.passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username string
+		ResetURL string
+		Expiry   string
+	}{
+		Username: user.Username,
+		ResetURL: resetURL,
+		Expiry:   app.config.mail.passwordResetExpiry.String(),
+	}
+
+	go func() {
+		status, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error sending password reset email", "user_id", user.ID, "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status)
+	}()
+
+	app.logger.Infow("password reset requested", "user_id", user.ID, "ip", clientIP(r))
+
+	return nil
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a password reset token. Every session of the user is logged out
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload ResetPasswordPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.ResetPassword(ctx, payload.Token, payload.Password)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// whoever knew the old password must not stay logged in
+	err = app.revokeAllTokens(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("password reset", "user_id", user.ID, "ip", clientIP(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/ratelimiter"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestForgotPassword(t *testing.T) {
+	cfg := config{
+		mail: mailConfig{passwordResetExpiry: time.Hour},
+		activationRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 2,
+			TimeFrame:            time.Hour,
+			Enabled:              true,
+		},
+	}
+
+	newForgotRequest := func(t *testing.T, email string) *http.Request {
+		body := `{"email": "` + email + `"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/password/forgot", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return req
+	}
+
+	t.Run("should answer unknown and known emails the same way", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		mux := app.mount()
+
+		known := executeRequest(newForgotRequest(t, "gopher@example.com"), mux)
+		unknown := executeRequest(newForgotRequest(t, store.MockUnknownEmail), mux)
+
+		checkResponseCode(t, http.StatusAccepted, known.Code)
+		checkResponseCode(t, http.StatusAccepted, unknown.Code)
+
+		if known.Body.String() != unknown.Body.String() {
+			t.Errorf("expected identical responses, got %q and %q", known.Body.String(), unknown.Body.String())
+		}
+	})
+
+	t.Run("should throttle requests per email", func(t *testing.T) {
+		app := newTestApplication(t, cfg)
+		mux := app.mount()
+
+		for i := 0; i < cfg.activationRateLimiter.RequestsPerTimeFrame+1; i++ {
+			email := "gopher@example.com"
+			if i%2 == 0 {
+				email = "Gopher@example.com"
+			}
+
+			rr := executeRequest(newForgotRequest(t, email), mux)
+
+			if i < cfg.activationRateLimiter.RequestsPerTimeFrame {
+				checkResponseCode(t, http.StatusAccepted, rr.Code)
+			} else {
+				checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+			}
+		}
+
+		// another email keeps its own budget
+		rr := executeRequest(newForgotRequest(t, "other@example.com"), mux)
+
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	newResetRequest := func(t *testing.T, token string) *http.Request {
+		body := `{"token": "` + token + `", "password": "new-password"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/password/reset", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return req
+	}
+
+	createReset := func(t *testing.T, app *application, token string, expiry time.Duration) {
+		err := app.store.Users.CreatePasswordReset(context.Background(), 1, hashToken(token), expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should reset the password once per token", func(t *testing.T) {
+		app := newTestApplication(t, config{})
+		mux := app.mount()
+
+		createReset(t, app, "a-reset-token", time.Hour)
+
+		rr := executeRequest(newResetRequest(t, "a-reset-token"), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newResetRequest(t, "a-reset-token"), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should invalidate the other tokens of the user", func(t *testing.T) {
+		app := newTestApplication(t, config{})
+		mux := app.mount()
+
+		createReset(t, app, "first-reset-token", time.Hour)
+		createReset(t, app, "second-reset-token", time.Hour)
+
+		rr := executeRequest(newResetRequest(t, "first-reset-token"), mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = executeRequest(newResetRequest(t, "second-reset-token"), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		app := newTestApplication(t, config{})
+		mux := app.mount()
+
+		createReset(t, app, "an-expired-token", -time.Minute)
+
+		rr := executeRequest(newResetRequest(t, "an-expired-token"), mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
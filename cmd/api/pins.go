@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// maxPinnedPosts is how many posts users can pin on their profile.
+const maxPinnedPosts = 3
+
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins one of the user's published posts on their profile, after the ones pinned already. Up to 3 posts can be pinned
+//	@Tags			posts
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/pin [put]
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("only published posts can be pinned"))
+		return
+	}
+
+	err := app.store.Pins.Create(r.Context(), user.ID, post.ID, maxPinnedPosts)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrTooManyPins):
+			app.conflictResponse(w, r, fmt.Errorf("up to %d posts can be pinned", maxPinnedPosts))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Removes one of the user's posts from their profile
+//	@Tags			posts
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/pin [delete]
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	err := app.store.Pins.Delete(r.Context(), getUserFromContext(r).ID, getPostFromCtx(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ReorderPinsPayload struct {
+	// every pinned post, in the new order
+	PostIDs []int64 `json:"post_ids" validate:"max=3,unique"`
+}
+
+// ReorderPins godoc
+//
+//	@Summary		Reorders the pinned posts
+//	@Description	Sets the order of the posts pinned on the user's profile, given every one of them
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		ReorderPinsPayload	true	"Pinned posts"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/pins [put]
+func (app *application) reorderPinsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload ReorderPinsPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err := app.store.Pins.Reorder(r.Context(), getUserFromContext(r).ID, payload.PostIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errors.New("post_ids must list each pinned post"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinnedPosts(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	request := func(t *testing.T, userID int64, method, path, body string) (int, string) {
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should pin the user's own posts", func(t *testing.T) {
+		for _, id := range []string{"1", "2", "3", "1"} {
+			code, _ := request(t, 1, http.MethodPut, "/v1/posts/"+id+"/pin", "")
+			checkResponseCode(t, http.StatusNoContent, code)
+		}
+
+		code, res := request(t, 2, http.MethodGet, "/v1/posts/1", "")
+		checkResponseCode(t, http.StatusOK, code)
+
+		if !strings.Contains(res, `"pinned":true`) {
+			t.Errorf("expected a pinned post, got %s", res)
+		}
+	})
+
+	t.Run("should pin up to three posts", func(t *testing.T) {
+		code, _ := request(t, 1, http.MethodPut, "/v1/posts/4/pin", "")
+		checkResponseCode(t, http.StatusConflict, code)
+	})
+
+	t.Run("should not pin the posts of others", func(t *testing.T) {
+		code, _ := request(t, 2, http.MethodPut, "/v1/posts/4/pin", "")
+		checkResponseCode(t, http.StatusForbidden, code)
+	})
+
+	t.Run("should not pin drafts", func(t *testing.T) {
+		code, _ := request(t, 1, http.MethodPut, "/v1/posts/402/pin", "")
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should reorder the pinned posts", func(t *testing.T) {
+		code, _ := request(t, 1, http.MethodPut, "/v1/users/me/pins", `{"post_ids": [3, 1, 2]}`)
+		checkResponseCode(t, http.StatusNoContent, code)
+
+		for _, body := range []string{`{"post_ids": [3, 1]}`, `{"post_ids": [3, 1, 4]}`, `{"post_ids": [3, 3, 1]}`} {
+			code, _ := request(t, 1, http.MethodPut, "/v1/users/me/pins", body)
+			if code != http.StatusBadRequest {
+				t.Errorf("%s: expected response code %d. Got %d", body, http.StatusBadRequest, code)
+			}
+		}
+	})
+
+	t.Run("should unpin a post", func(t *testing.T) {
+		code, _ := request(t, 1, http.MethodDelete, "/v1/posts/3/pin", "")
+		checkResponseCode(t, http.StatusNoContent, code)
+
+		code, _ = request(t, 1, http.MethodDelete, "/v1/posts/3/pin", "")
+		checkResponseCode(t, http.StatusNotFound, code)
+	})
+
+	t.Run("should fetch the profile with the pinned posts", func(t *testing.T) {
+		code, res := request(t, 2, http.MethodGet, "/v1/users/1/profile", "")
+		checkResponseCode(t, http.StatusOK, code)
+
+		if !strings.Contains(res, `"pinned_posts":[]`) {
+			t.Errorf("expected the pinned posts, got %s", res)
+		}
+	})
+
+	t.Run("should list the posts of a user", func(t *testing.T) {
+		code, _ := request(t, 2, http.MethodGet, "/v1/users/1/posts", "")
+		checkResponseCode(t, http.StatusOK, code)
+
+		code, _ = request(t, 2, http.MethodGet, "/v1/users/1/posts?limit=100", "")
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+}
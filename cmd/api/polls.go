@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type CreatePollPayload struct {
+	Options        []string `json:"options" validate:"min=2,max=6,unique,dive,required,max=100"`
+	MultipleChoice bool     `json:"multiple_choice"`
+	// in the future, after the post is published
+	ClosesAt time.Time `json:"closes_at" validate:"required"`
+}
+
+// newPoll makes the poll of the post from the payload, the post's status
+// being set already.
+func newPoll(payload *CreatePollPayload, post *store.Post) (*store.Poll, error) {
+
+	opensAt := time.Now()
+	if post.PublishAt != nil {
+		opensAt = *post.PublishAt
+	}
+
+	if !payload.ClosesAt.After(opensAt) {
+		return nil, errors.New("polls need a closes_at after the post is published")
+	}
+
+	poll := &store.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		ClosesAt:       payload.ClosesAt,
+		ViewerVotes:    []int64{},
+	}
+
+	for _, text := range payload.Options {
+		poll.Options = append(poll.Options, store.PollOption{Text: text})
+	}
+
+	return poll, nil
+}
+
+type VotePayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"min=1,unique"`
+}
+
+// VoteInPoll godoc
+//
+//	@Summary		Votes in the poll of a post
+//	@Description	Votes for one option of a single-choice poll or for several of a multiple-choice one, replacing the previous votes of the user. Votes are anonymous and refused once the poll is closed
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int			true	"Post ID"
+//	@Param			payload	body		VotePayload	true	"Chosen options"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/poll/votes [post]
+func (app *application) voteHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if post.Poll == nil {
+		app.notFoundResponse(w, r, errors.New("post has no poll"))
+		return
+	}
+
+	var payload VotePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !post.Poll.MultipleChoice && len(payload.OptionIDs) > 1 {
+		app.badRequestResponse(w, r, errors.New("single-choice polls take one option"))
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("only published posts can be voted on"))
+		return
+	}
+
+	ctx := r.Context()
+
+	err := app.store.Polls.Vote(ctx, post.ID, user.ID, payload.OptionIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrPollClosed):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errors.New("unknown poll option"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	poll, err := app.store.Polls.Get(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, poll)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnvoteInPoll godoc
+//
+//	@Summary		Withdraws the votes of the user
+//	@Description	Withdraws the votes of the user from the poll of a post, refused once the poll is closed
+//	@Tags			posts
+//	@Param			id	path	int	true	"Post ID"
+//	@Success		204
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/poll/votes [delete]
+func (app *application) unvoteHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	if post.Poll == nil {
+		app.notFoundResponse(w, r, errors.New("post has no poll"))
+		return
+	}
+
+	err := app.store.Polls.Unvote(r.Context(), post.ID, getUserFromContext(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrPollClosed):
+			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestPolls(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+
+		return rr.Code, rr.Body.String()
+	}
+
+	closesAt := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	t.Run("should create a post with a poll", func(t *testing.T) {
+		body := fmt.Sprintf(`{"title": "gophers or rustaceans?", "content": "vote!",
+			"poll": {"options": ["gophers", "rustaceans"], "closes_at": %q}}`, closesAt)
+
+		code, res := request(t, http.MethodPost, "/v1/posts", body)
+		checkResponseCode(t, http.StatusCreated, code)
+
+		var post struct {
+			Data store.Post `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(res), &post); err != nil {
+			t.Fatal(err)
+		}
+
+		if post.Data.Poll == nil || len(post.Data.Poll.Options) != 2 || post.Data.Poll.MultipleChoice {
+			t.Errorf("got poll %+v", post.Data.Poll)
+		}
+	})
+
+	t.Run("should not create invalid polls", func(t *testing.T) {
+		for _, poll := range []string{
+			fmt.Sprintf(`{"options": ["gophers"], "closes_at": %q}`, closesAt),
+			fmt.Sprintf(`{"options": ["a", "b", "c", "d", "e", "f", "g"], "closes_at": %q}`, closesAt),
+			fmt.Sprintf(`{"options": ["gophers", "gophers"], "closes_at": %q}`, closesAt),
+			`{"options": ["gophers", "rustaceans"], "closes_at": "2020-01-01T00:00:00Z"}`,
+			`{"options": ["gophers", "rustaceans"]}`,
+		} {
+			code, _ := request(t, http.MethodPost, "/v1/posts", `{"title": "poll", "content": "vote!", "poll": `+poll+`}`)
+			if code != http.StatusBadRequest {
+				t.Errorf("%s: expected response code %d. Got %d", poll, http.StatusBadRequest, code)
+			}
+		}
+	})
+
+	t.Run("should vote in an open poll", func(t *testing.T) {
+		code, res := request(t, http.MethodPost, "/v1/posts/405/poll/votes", `{"option_ids": [1]}`)
+		checkResponseCode(t, http.StatusOK, code)
+
+		if !strings.Contains(res, `"options"`) {
+			t.Errorf("expected the poll, got %s", res)
+		}
+	})
+
+	t.Run("should take one option on single-choice polls", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v1/posts/405/poll/votes", `{"option_ids": [1, 2]}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should not vote for unknown options", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v1/posts/405/poll/votes", `{"option_ids": [9]}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+
+		code, _ = request(t, http.MethodPost, "/v1/posts/405/poll/votes", `{"option_ids": []}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should refuse votes once the poll is closed", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v1/posts/406/poll/votes", `{"option_ids": [1]}`)
+		checkResponseCode(t, http.StatusConflict, code)
+
+		code, _ = request(t, http.MethodDelete, "/v1/posts/406/poll/votes", "")
+		checkResponseCode(t, http.StatusConflict, code)
+	})
+
+	t.Run("should withdraw votes", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v1/posts/405/poll/votes", "")
+		checkResponseCode(t, http.StatusNoContent, code)
+	})
+
+	t.Run("should not vote on posts without a poll", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v1/posts/1/poll/votes", `{"option_ids": [1]}`)
+		checkResponseCode(t, http.StatusNotFound, code)
+	})
+
+	t.Run("should show the poll with the post", func(t *testing.T) {
+		code, res := request(t, http.MethodGet, "/v1/posts/405", "")
+		checkResponseCode(t, http.StatusOK, code)
+
+		if !strings.Contains(res, `"viewer_votes":[]`) {
+			t.Errorf("expected the poll, got %s", res)
+		}
+	})
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/entities"
 	"github.com/high-la/gopher-social/internal/store"
 )
 
@@ -18,12 +21,22 @@ type CreatePostPayload struct {
 	Title   string   `json:"title" validate:"required,max=100"`
 	Content string   `json:"content" validate:"required,max=1000"`
 	Tags    []string `json:"tags"`
+	// makes it a quote post of the given post
+	QuotedPostID *int64 `json:"quoted_post_id" validate:"omitempty,gt=0"`
+	// public by default
+	Visibility string `json:"visibility" validate:"omitempty,oneof=public followers private"`
+	// published by default
+	Status string `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	// required to schedule the post, in the future
+	PublishAt *time.Time `json:"publish_at"`
+	// attaches a poll, which can not be changed afterwards
+	Poll *CreatePollPayload `json:"poll"`
 }
 
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, or a quote post of another one when quoted_post_id is set. Mentions and hashtags are parsed from the title and the content, hashtags are added to the tags. Posts are public unless visibility is followers or private, and published right away unless status is draft or scheduled at publish_at. A poll of 2 to 6 options can be attached
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -51,15 +64,62 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 	user := getUserFromContext(r)
 
+	found := parsePostEntities(payload.Title, payload.Content)
+
+	if payload.Visibility == "" {
+		payload.Visibility = store.VisibilityPublic
+	}
+	if payload.Status == "" {
+		payload.Status = store.PostStatusPublished
+	}
+
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		Tags:         mergeTags(payload.Tags, nil, entities.Hashtags(found.Entities())),
+		UserID:       user.ID,
+		QuotedPostID: payload.QuotedPostID,
+		Visibility:   payload.Visibility,
+		Entities:     found,
+		Attachments:  store.Attachments{},
+	}
+
+	if err := setPostStatus(post, payload.Status, payload.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Poll != nil {
+		if post.Poll, err = newPoll(payload.Poll, post); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
 	}
 
 	ctx := r.Context()
 
+	if payload.QuotedPostID != nil {
+		quoted, err := app.store.Posts.GetByID(ctx, *payload.QuotedPostID)
+		if err == nil {
+			var visible bool
+			// a post the user can not see is not told apart from a missing one
+			if visible, err = app.isPostVisible(ctx, user, quoted); err == nil && (!visible || quoted.Status != store.PostStatusPublished) {
+				err = store.ErrNotFound
+			}
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, errors.New("quoted post not found"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		post.QuotedPost = quoted
+	}
+
 	if err := app.store.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -75,12 +135,12 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 // GetPost godoc
 //
 //	@Summary		Fetches a post
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID, if visible to the user. The quoted post is left out when it is not
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
+//	@Success		200	{object}	store.PostWithMetadata
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
@@ -88,16 +148,57 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+	ctx := r.Context()
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	reactions, err := app.store.Reactions.GetForPost(ctx, post.ID, user.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	post.Comments = comments
+	if post.QuotedPost != nil {
+		visible, err := app.isPostVisible(ctx, user, post.QuotedPost)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			post.QuotedPost = nil
+		}
+	}
 
-	err = app.jsonResponse(w, http.StatusOK, post)
+	bookmarked, err := app.store.Bookmarks.Exists(ctx, user.ID, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	pinned, err := app.store.Pins.Exists(ctx, post.UserID, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// with the votes of the user
+	if post.Poll != nil {
+		post.Poll, err = app.store.Polls.Get(ctx, post.ID, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.setAttachmentURLs(post.Attachments)
+
+	res := store.PostWithMetadata{
+		Post:       *post,
+		Reactions:  *reactions,
+		Bookmarked: bookmarked,
+		Pinned:     pinned,
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, res)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -108,12 +209,17 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 type UpdatePostPayload struct {
 	Title   *string `json:"title" validate:"omitempty,max=100"`
 	Content *string `json:"content" validate:"omitempty,max=1000"`
+	// only the author can change it
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers private"`
+	// only the author can change them, published posts stay published
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID, keeping the previous versions as revisions. Only the author can change its visibility, and publish or schedule it
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -122,6 +228,7 @@ type UpdatePostPayload struct {
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -150,13 +257,44 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
+	if payload.Visibility != nil {
+		if post.UserID != getUserFromContext(r).ID {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		post.Visibility = *payload.Visibility
+	}
+	if payload.Status != nil || payload.PublishAt != nil {
+		if post.UserID != getUserFromContext(r).ID {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		status := post.Status
+		if payload.Status != nil {
+			status = *payload.Status
+		}
+
+		if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	// the hashtags no longer in the text are dropped from the tags
+	found := parsePostEntities(post.Title, post.Content)
+	post.Tags = mergeTags(post.Tags, entities.Hashtags(post.Entities.Entities()), entities.Hashtags(found.Entities()))
+	post.Entities = found
 
-	err = app.store.Posts.Update(r.Context(), post)
+	// moderators editing the post are recorded as the editor
+	err = app.store.Posts.Update(r.Context(), post, getUserFromContext(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	app.setAttachmentURLs(post.Attachments)
+
 	err = app.jsonResponse(w, http.StatusOK, post)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -167,7 +305,7 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 // DeletePost godoc
 //
 //	@Summary		Delete a post
-//	@Description	Delete a post by ID
+//	@Description	Moves a post to the trash, where its author or an admin can restore it until purged
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -206,6 +344,107 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ListDrafts godoc
+//
+//	@Summary		Lists the drafts of the user
+//	@Description	Lists the drafts and scheduled posts of the authenticated user, filtered like the feed
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setFeedAttachmentURLs(posts)
+
+	err = app.jsonResponse(w, http.StatusOK, posts)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setPostStatus sets the status of the post and when it is published.
+// Scheduled posts need a publish_at in the future and published posts can
+// not go back to drafts.
+func setPostStatus(post *store.Post, status string, publishAt *time.Time) error {
+
+	if post.Status == store.PostStatusPublished && status != store.PostStatusPublished {
+		return errors.New("published posts can not be unpublished")
+	}
+
+	if status == store.PostStatusScheduled {
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+	} else if publishAt != nil {
+		return errors.New("publish_at is only set on scheduled posts")
+	}
+
+	post.Status = status
+	post.PublishAt = publishAt
+
+	return nil
+}
+
+// parsePostEntities finds the mentions and hashtags of a post.
+func parsePostEntities(title, content string) store.PostEntities {
+
+	found := store.PostEntities{}
+
+	for _, field := range []struct{ name, text string }{
+		{store.EntityFieldTitle, title},
+		{store.EntityFieldContent, content},
+	} {
+		for _, e := range entities.Parse(field.text) {
+			found = append(found, store.PostEntity{
+				Type:  e.Type,
+				Field: field.name,
+				Start: e.Start,
+				End:   e.End,
+				Text:  e.Text,
+			})
+		}
+	}
+
+	return found
+}
+
+// mergeTags removes the given tags then adds the others, without duplicates.
+func mergeTags(tags, remove, add []string) []string {
+
+	merged := []string{}
+
+	for _, tag := range slices.Concat(tags, add) {
+		if !slices.Contains(merged, tag) && (!slices.Contains(remove, tag) || slices.Contains(add, tag)) {
+			merged = append(merged, tag)
+		}
+	}
+
+	return merged
+}
+
 // .
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 
@@ -222,6 +461,13 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 		ctx := r.Context()
 
 		post, err := app.store.Posts.GetByID(ctx, id)
+		if err == nil {
+			var visible bool
+			// a post the user can not see is not told apart from a missing one
+			if visible, err = app.isPostVisible(ctx, getUserFromContext(r), post); err == nil && !visible {
+				err = store.ErrNotFound
+			}
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, store.ErrNotFound):
@@ -237,6 +483,31 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// isPostVisible tells whether the user can see the post: its author always
+// can, others only once it is published, followers of the author can see
+// followers-only posts and moderators can see every post for review.
+func (app *application) isPostVisible(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+
+	if post.UserID == user.ID {
+		return true, nil
+	}
+
+	published := post.Status == store.PostStatusPublished
+
+	if published && post.Visibility == store.VisibilityPublic {
+		return true, nil
+	}
+
+	if published && post.Visibility == store.VisibilityFollowers {
+		following, err := app.store.Followers.IsFollowing(ctx, user.ID, post.UserID)
+		if err != nil || following {
+			return following, err
+		}
+	}
+
+	return app.checkRolePrecedence(ctx, user, "moderator")
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 
 	post, _ := r.Context().Value(postCtx).(*store.Post)
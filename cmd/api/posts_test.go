@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestCreatePostEntities(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(1, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"title": "Hello #Go", "content": "thanks @gopher for #go and #generics", "tags": ["news"]}`
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data store.Post `json:"data"`
+	}
+
+	err = json.NewDecoder(rr.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should add the hashtags to the tags", func(t *testing.T) {
+		want := []string{"news", "go", "generics"}
+		if !reflect.DeepEqual(res.Data.Tags, want) {
+			t.Errorf("expected tags %v, got %v", want, res.Data.Tags)
+		}
+	})
+
+	t.Run("should return the entities with their offsets", func(t *testing.T) {
+		want := store.PostEntities{
+			{Type: "hashtag", Field: "title", Start: 6, End: 9, Text: "go"},
+			{Type: "mention", Field: "content", Start: 7, End: 14, Text: "gopher"},
+			{Type: "hashtag", Field: "content", Start: 19, End: 22, Text: "go"},
+			{Type: "hashtag", Field: "content", Start: 27, End: 36, Text: "generics"},
+		}
+		if !reflect.DeepEqual(res.Data.Entities, want) {
+			t.Errorf("expected entities %+v, got %+v", want, res.Data.Entities)
+		}
+	})
+
+	t.Run("should list the mentions of the user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/mentions?search=thanks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+}
+
+func TestMergeTags(t *testing.T) {
+	tests := []struct {
+		name              string
+		tags, remove, add []string
+		want              []string
+	}{
+		{name: "adds without duplicates", tags: []string{"go"}, add: []string{"go", "rust"}, want: []string{"go", "rust"}},
+		{name: "removes the old hashtags", tags: []string{"news", "go"}, remove: []string{"go"}, want: []string{"news"}},
+		{name: "keeps the hashtags still there", tags: []string{"go"}, remove: []string{"go"}, add: []string{"go"}, want: []string{"go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeTags(tt.tags, tt.remove, tt.add)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostVisibility(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	private := fmt.Sprintf("/v1/posts/%d", store.MockPrivatePostID)
+
+	request := func(t *testing.T, userID int64, method, path, body string) int {
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should show a private post to its author", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 1, http.MethodGet, private, ""))
+	})
+
+	t.Run("should hide a private post from other users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, 2, http.MethodGet, private, ""))
+		checkResponseCode(t, http.StatusNotFound, request(t, 2, http.MethodPut, private+"/bookmark", ""))
+	})
+
+	t.Run("should show a private post to moderators", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, store.MockAdminID, http.MethodGet, private, ""))
+	})
+
+	t.Run("should not quote a hidden post", func(t *testing.T) {
+		body := fmt.Sprintf(`{"title": "so true", "content": "this one", "quoted_post_id": %d}`, store.MockPrivatePostID)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 2, http.MethodPost, "/v1/posts", body))
+	})
+
+	t.Run("should reject an unknown visibility", func(t *testing.T) {
+		body := `{"title": "hello", "content": "world", "visibility": "friends"}`
+		checkResponseCode(t, http.StatusBadRequest, request(t, 2, http.MethodPost, "/v1/posts", body))
+	})
+
+	t.Run("should let the author change the visibility", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 1, http.MethodPatch, "/v1/posts/1", `{"visibility": "followers"}`))
+	})
+
+	t.Run("should not let moderators change the visibility", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, store.MockAdminID, http.MethodPatch, "/v1/posts/1", `{"visibility": "private"}`))
+	})
+}
+
+func TestPostStatus(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	draft := fmt.Sprintf("/v1/posts/%d", store.MockDraftPostID)
+
+	request := func(t *testing.T, userID int64, method, path, body string) int {
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should create a draft", func(t *testing.T) {
+		body := `{"title": "soon", "content": "not yet", "status": "draft"}`
+		checkResponseCode(t, http.StatusCreated, request(t, 2, http.MethodPost, "/v1/posts", body))
+	})
+
+	t.Run("should schedule a post in the future", func(t *testing.T) {
+		publishAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+		body := fmt.Sprintf(`{"title": "soon", "content": "not yet", "status": "scheduled", "publish_at": %q}`, publishAt)
+		checkResponseCode(t, http.StatusCreated, request(t, 2, http.MethodPost, "/v1/posts", body))
+	})
+
+	t.Run("should not schedule a post in the past", func(t *testing.T) {
+		publishAt := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		body := fmt.Sprintf(`{"title": "soon", "content": "not yet", "status": "scheduled", "publish_at": %q}`, publishAt)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 2, http.MethodPost, "/v1/posts", body))
+		checkResponseCode(t, http.StatusBadRequest, request(t, 2, http.MethodPost, "/v1/posts", `{"title": "soon", "content": "not yet", "status": "scheduled"}`))
+	})
+
+	t.Run("should show a draft to its author only", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 1, http.MethodGet, draft, ""))
+		checkResponseCode(t, http.StatusNotFound, request(t, 2, http.MethodGet, draft, ""))
+	})
+
+	t.Run("should not repost a draft", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 1, http.MethodPut, draft+"/repost", ""))
+	})
+
+	t.Run("should publish a draft", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 1, http.MethodPatch, draft, `{"status": "published"}`))
+	})
+
+	t.Run("should not unpublish a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 1, http.MethodPatch, "/v1/posts/1", `{"status": "draft"}`))
+	})
+
+	t.Run("should list the drafts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 2, http.MethodGet, "/v1/users/me/drafts", ""))
+	})
+}
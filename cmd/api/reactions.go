@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// GetPostReactions godoc
+//
+//	@Summary		Fetches the reactions to a post
+//	@Description	Counts the reactions to a post by kind, along with the one of the authenticated user
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	store.Reactions
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/reactions [get]
+func (app *application) getPostReactionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	app.postReactionsResponse(w, r)
+}
+
+// ReactToPost godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Reacts to a post with one of like, love, laugh, wow, sad or angry. It replaces the previous reaction of the user
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"
+//	@Success		200		{object}	store.Reactions
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/reactions/{kind} [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	err = app.store.Reactions.SetOnPost(r.Context(), post.ID, user.ID, kind)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.postReactionsResponse(w, r)
+}
+
+// UnreactToPost godoc
+//
+//	@Summary		Withdraws a reaction to a post
+//	@Description	Withdraws the reaction of the authenticated user to a post
+//	@Tags			reactions
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/reactions/{kind} [delete]
+func (app *application) unreactToPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	err = app.store.Reactions.RemoveFromPost(r.Context(), post.ID, user.ID, kind)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommentReactions godoc
+//
+//	@Summary		Fetches the reactions to a comment
+//	@Description	Counts the reactions to a comment by kind, along with the one of the authenticated user
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			id			path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		200			{object}	store.Reactions
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID}/reactions [get]
+func (app *application) getCommentReactionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	app.commentReactionsResponse(w, r)
+}
+
+// ReactToComment godoc
+//
+//	@Summary		Reacts to a comment
+//	@Description	Reacts to a comment with one of like, love, laugh, wow, sad or angry. It replaces the previous reaction of the user
+//	@Tags			reactions
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			kind		path		string	true	"Reaction kind"
+//	@Success		200			{object}	store.Reactions
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID}/reactions/{kind} [put]
+func (app *application) reactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	comment := getCommentFromCtx(r)
+
+	// placeholders of deleted comments are not there to be reacted to
+	if comment.Deleted {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	err = app.store.Reactions.SetOnComment(r.Context(), comment.ID, user.ID, kind)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.commentReactionsResponse(w, r)
+}
+
+// UnreactToComment godoc
+//
+//	@Summary		Withdraws a reaction to a comment
+//	@Description	Withdraws the reaction of the authenticated user to a comment
+//	@Tags			reactions
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Param			kind		path		string	true	"Reaction kind"
+//	@Success		204			{string}	string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID}/reactions/{kind} [delete]
+func (app *application) unreactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	kind, err := reactionKindParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	comment := getCommentFromCtx(r)
+
+	err = app.store.Reactions.RemoveFromComment(r.Context(), comment.ID, user.ID, kind)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) postReactionsResponse(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	reactions, err := app.store.Reactions.GetForPost(r.Context(), post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, reactions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) commentReactionsResponse(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	comment := getCommentFromCtx(r)
+
+	reactions, err := app.store.Reactions.GetForComment(r.Context(), comment.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, reactions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func reactionKindParam(r *http.Request) (string, error) {
+
+	kind := chi.URLParam(r, "kind")
+	if _, ok := store.ReactionKinds[kind]; !ok {
+		return "", fmt.Errorf("unknown reaction %q", kind)
+	}
+
+	return kind, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReactions(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path string) int {
+		req, err := http.NewRequest(method, path, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should react to a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/posts/1/reactions/like"))
+	})
+
+	t.Run("should react to a comment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/posts/1/comments/1/reactions/laugh"))
+	})
+
+	t.Run("should reject unknown reactions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/posts/1/reactions/shrug"))
+	})
+
+	t.Run("should not withdraw a missing reaction", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/reactions/love"))
+	})
+
+	t.Run("should list the reactions to a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1/reactions"))
+	})
+}
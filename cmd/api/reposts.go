@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// Repost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Reshares a published post of another user with the followers of the authenticated user. To add commentary, create a quote post instead
+//	@Tags			posts
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/repost [put]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestResponse(w, r, errors.New("only published posts can be reposted"))
+		return
+	}
+
+	if post.UserID == user.ID {
+		app.badRequestResponse(w, r, errors.New("you cannot repost your own post"))
+		return
+	}
+
+	err := app.store.Reposts.Create(r.Context(), user.ID, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unrepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Undoes a repost of the authenticated user
+//	@Tags			posts
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/repost [delete]
+func (app *application) unrepostHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	post := getPostFromCtx(r)
+
+	err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestReposts(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should repost a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/posts/1/repost", ""))
+	})
+
+	t.Run("should not repost an own post", func(t *testing.T) {
+		ownToken, err := app.generateToken(1, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/repost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+ownToken)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req, mux).Code)
+	})
+
+	t.Run("should not repost a missing post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, fmt.Sprintf("/v1/posts/%d/repost", store.MockMissingPostID), ""))
+	})
+
+	t.Run("should not undo a missing repost", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/repost", ""))
+	})
+
+	t.Run("should quote a post", func(t *testing.T) {
+		body := `{"title": "so true", "content": "this one", "quoted_post_id": 1}`
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/posts", body))
+	})
+
+	t.Run("should not quote a missing post", func(t *testing.T) {
+		body := fmt.Sprintf(`{"title": "so true", "content": "this one", "quoted_post_id": %d}`, store.MockMissingPostID)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts", body))
+	})
+}
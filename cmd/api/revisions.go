@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/diff"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// PostRevisionDiff holds the word edits turning a version of a post into
+// another.
+type PostRevisionDiff struct {
+	PostID  int64       `json:"post_id"`
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Edit `json:"title"`
+	Content []diff.Edit `json:"content"`
+}
+
+// ListPostRevisions godoc
+//
+//	@Summary		Lists the revisions of a post
+//	@Description	Lists every version of a post, latest first, along with who edited it
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	[]store.PostRevision
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions [get]
+func (app *application) listPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	revisions, err := app.store.PostRevisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, revisions)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetPostRevision godoc
+//
+//	@Summary		Fetches a revision of a post
+//	@Description	Fetches a post as it was at the given version
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version"
+//	@Success		200		{object}	store.PostRevision
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions/{version} [get]
+func (app *application) getPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revision, err := app.store.PostRevisions.Get(r.Context(), post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, revision)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Compares two revisions of a post
+//	@Description	Lists the word edits of the title and the content between two versions of a post. By default the current version is compared with the one before
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			from	query		int	false	"Older version"
+//	@Param			to		query		int	false	"Newer version"
+//	@Success		200		{object}	PostRevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	post := getPostFromCtx(r)
+
+	to, err := versionQueryParam(r, "to", post.Version)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	from, err := versionQueryParam(r, "from", to-1)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	revisions := make([]*store.PostRevision, 2)
+
+	for i, version := range []int{from, to} {
+		revisions[i], err = app.store.PostRevisions.Get(ctx, post.ID, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	res := PostRevisionDiff{
+		PostID:  post.ID,
+		From:    from,
+		To:      to,
+		Title:   diff.Words(revisions[0].Title, revisions[1].Title),
+		Content: diff.Words(revisions[0].Content, revisions[1].Content),
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, res)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// versionQueryParam reads a version from the query string, or returns
+// fallback when it is not set.
+func versionQueryParam(r *http.Request, name string, fallback int) (int, error) {
+
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/diff"
+)
+
+func TestPostRevisions(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	token, err := app.generateToken(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, path string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should list the revisions", func(t *testing.T) {
+		code, _ := request(t, "/v1/posts/1/revisions")
+		checkResponseCode(t, http.StatusOK, code)
+	})
+
+	t.Run("should fetch a revision", func(t *testing.T) {
+		code, _ := request(t, "/v1/posts/1/revisions/1")
+		checkResponseCode(t, http.StatusOK, code)
+	})
+
+	t.Run("should not fetch a missing revision", func(t *testing.T) {
+		code, _ := request(t, "/v1/posts/1/revisions/7")
+		checkResponseCode(t, http.StatusNotFound, code)
+
+		code, _ = request(t, "/v1/posts/1/revisions/latest")
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should diff two revisions", func(t *testing.T) {
+		code, body := request(t, "/v1/posts/1/revisions/diff?from=0&to=1")
+		checkResponseCode(t, http.StatusOK, code)
+
+		var res struct {
+			Data PostRevisionDiff `json:"data"`
+		}
+		if err := json.NewDecoder(strings.NewReader(body)).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []diff.Edit{
+			{Op: diff.OpEqual, Text: "hello "},
+			{Op: diff.OpDelete, Text: "gophers"},
+			{Op: diff.OpInsert, Text: "rustaceans"},
+		}
+		if !reflect.DeepEqual(res.Data.Content, want) {
+			t.Errorf("content diff = %+v, want %+v", res.Data.Content, want)
+		}
+	})
+
+	t.Run("should not diff before the first version", func(t *testing.T) {
+		code, _ := request(t, "/v1/posts/1/revisions/diff")
+		checkResponseCode(t, http.StatusNotFound, code)
+	})
+}
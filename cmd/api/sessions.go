@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+type SessionWithCurrent struct {
+	store.Session
+	// set on the session of the token used for the request
+	Current bool `json:"current"`
+}
+
+// ListSessions godoc
+//
+//	@Summary		Lists sessions
+//	@Description	Lists the devices the authenticated user is logged in on
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]SessionWithCurrent
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	currentID, _ := getClaimsFromContext(r)["sid"].(string)
+
+	// a session unused for as long as a refresh token lives is over
+	seenSince := time.Now().Add(-app.config.auth.token.refreshExpiry)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID, seenSince)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	res := make([]SessionWithCurrent, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, SessionWithCurrent{Session: session, Current: session.ID == currentID})
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, res)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revokes a session
+//	@Description	Logs the authenticated user out of a device. Its access tokens are rejected right away and its refresh tokens revoked
+//	@Tags			users
+//	@Param			sessionID	path		string	true	"Session ID"
+//	@Success		204			{string}	string
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	id := chi.URLParam(r, "sessionID")
+
+	// no session has a malformed id
+	if _, err := uuid.Parse(id); err != nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	err := app.store.Sessions.Revoke(r.Context(), id, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("session revoked", "user_id", user.ID, "session_id", id, "ip", clientIP(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sweepSessions deletes the sessions that were revoked or left unused for
+// longer than a refresh token lives.
+func (app *application) sweepSessions(ctx context.Context) error {
+
+	before := time.Now().Add(-app.config.auth.token.refreshExpiry)
+
+	sessions, err := app.store.Sessions.DeleteInactive(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	if sessions > 0 {
+		app.logger.Infow("sessions swept", "inactive_sessions", sessions)
+	}
+
+	return nil
+}
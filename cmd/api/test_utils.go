@@ -6,6 +6,9 @@ import (
 	"testing"
 
 	"github.com/high-la/gopher-social/internal/auth"
+	"github.com/high-la/gopher-social/internal/mailer"
+	"github.com/high-la/gopher-social/internal/ratelimiter"
+	"github.com/high-la/gopher-social/internal/storage"
 	"github.com/high-la/gopher-social/internal/store"
 	"github.com/high-la/gopher-social/internal/store/cache"
 	"go.uber.org/zap"
@@ -22,11 +25,28 @@ func newTestApplication(t *testing.T, cfg config) *application {
 
 	testAuth := &auth.TestAuthenticator{}
 
+	blobs, err := storage.NewLocal(t.TempDir(), "http://localhost:8080/v1/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
+		config:        cfg,
 		logger:        logger,
 		store:         mockStore,
 		cacheStorage:  mockCacheStore,
 		authenticator: testAuth,
+		rateLimiter: ratelimiter.NewFixedWindowLimiter(
+			cfg.rateLimiter.RequestsPerTimeFrame,
+			cfg.rateLimiter.TimeFrame,
+		),
+		activationRateLimiter: ratelimiter.NewFixedWindowLimiter(
+			cfg.activationRateLimiter.RequestsPerTimeFrame,
+			cfg.activationRateLimiter.TimeFrame,
+		),
+		mailer:     &mailer.TestMailer{},
+		blobs:      blobs,
+		mediaSlots: make(chan struct{}, max(1, cfg.media.maxConcurrentProcessing)),
 	}
 }
 
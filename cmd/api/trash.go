@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// Trash holds the deleted posts and comments of a user until they are
+// purged.
+type Trash struct {
+	Posts    []store.Post    `json:"posts"`
+	Comments []store.Comment `json:"comments"`
+}
+
+// ListTrash godoc
+//
+//	@Summary		Lists the trash of the user
+//	@Description	Lists the deleted posts and comments of the authenticated user, most recently deleted first. They can be restored until they are purged
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	Trash
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/trash [get]
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetDeletedByUserID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetDeletedByUserID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusOK, Trash{Posts: posts, Comments: comments})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a post
+//	@Description	Takes a deleted post out of the trash. Admins can restore the posts of others
+//	@Tags			posts
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		204	{string}	string
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	post, err := app.store.Posts.GetDeletedByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(ctx, getUserFromContext(r), post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	err = app.store.Posts.Restore(ctx, post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreComment godoc
+//
+//	@Summary		Restores a comment
+//	@Description	Takes a deleted comment out of the trash. Admins can restore the comments of others
+//	@Tags			comments
+//	@Param			id			path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{string}	string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentID}/restore [post]
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	comment, err := app.store.Comments.GetDeletedByID(ctx, id)
+	if err == nil && comment.PostID != post.ID {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canRestore(ctx, getUserFromContext(r), comment.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	err = app.store.Comments.Restore(ctx, comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser godoc
+//
+//	@Summary		Deletes a user
+//	@Description	Moves an account to the trash along with its posts and comments, and logs it out everywhere. Admins can delete the accounts of users with a lower role
+//	@Tags			users
+//	@Param			id	path		int	true	"User ID"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id} [delete]
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	actor := getUserFromContext(r)
+	ctx := r.Context()
+
+	if userID != actor.ID {
+		user, err := app.getUser(ctx, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(ctx, actor, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// admins can not delete their peers
+		if !allowed || user.Role.Level >= actor.Role.Level {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	err = app.store.Users.Delete(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.redisCfg.enabled {
+		if err := app.cacheStorage.Users.Delete(ctx, userID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.revokeAllTokens(ctx, userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user deleted", "user_id", userID, "by", actor.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser godoc
+//
+//	@Summary		Restores a user
+//	@Description	Takes an account out of the trash along with its posts and comments. Admins only, and not for accounts of their own role or above
+//	@Tags			users
+//	@Param			id	path		int	true	"User ID"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/restore [post]
+func (app *application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	actor := getUserFromContext(r)
+	ctx := r.Context()
+
+	allowed, err := app.checkRolePrecedence(ctx, actor, "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	user, err := app.store.Users.GetDeletedByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// admins can not restore their peers, like they can not delete them
+	if user.Role.Level >= actor.Role.Level {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	err = app.store.Users.Restore(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canRestore tells whether the user can take content out of the trash: its
+// owner and admins can.
+func (app *application) canRestore(ctx context.Context, user *store.User, ownerID int64) (bool, error) {
+
+	if user.ID == ownerID {
+		return true, nil
+	}
+
+	return app.checkRolePrecedence(ctx, user, "admin")
+}
+
+// purgeTrash removes for good the users, posts and comments deleted for
+// longer than the retention period.
+func (app *application) purgeTrash(ctx context.Context) error {
+
+	deletedBefore := time.Now().Add(-app.config.trash.retention)
+
+	users, err := app.store.Users.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	posts, err := app.store.Posts.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	comments, err := app.store.Comments.PurgeDeleted(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	if users > 0 || posts > 0 || comments > 0 {
+		app.logger.Infow("trash purged", "users", users, "posts", posts, "comments", comments)
+	}
+
+	return nil
+}
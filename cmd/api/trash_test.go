@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestTrash(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	request := func(t *testing.T, userID int64, method, path string) int {
+		token, err := app.generateToken(userID, time.Minute, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(method, path, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should list the trash", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, 2, http.MethodGet, "/v1/users/me/trash"))
+	})
+
+	t.Run("should let the author restore a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, 1, http.MethodPost, "/v1/posts/1/restore"))
+	})
+
+	t.Run("should let admins restore a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, store.MockAdminID, http.MethodPost, "/v1/posts/1/restore"))
+	})
+
+	t.Run("should not let others restore a post", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 2, http.MethodPost, "/v1/posts/1/restore"))
+	})
+
+	t.Run("should not restore a post missing from the trash", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, 1, http.MethodPost, fmt.Sprintf("/v1/posts/%d/restore", store.MockMissingPostID)))
+	})
+
+	t.Run("should restore a comment of the post only", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, 1, http.MethodPost, "/v1/posts/1/comments/3/restore"))
+		checkResponseCode(t, http.StatusNotFound, request(t, 1, http.MethodPost, "/v1/posts/2/comments/3/restore"))
+		checkResponseCode(t, http.StatusForbidden, request(t, 2, http.MethodPost, "/v1/posts/1/comments/3/restore"))
+	})
+
+	t.Run("should let users delete their account", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, 2, http.MethodDelete, "/v1/users/2"))
+	})
+
+	t.Run("should not let users delete others", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 2, http.MethodDelete, "/v1/users/3"))
+	})
+
+	t.Run("should let admins delete users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, store.MockAdminID, http.MethodDelete, "/v1/users/3"))
+	})
+
+	t.Run("should let admins only restore users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, store.MockAdminID, http.MethodPost, "/v1/users/3/restore"))
+		checkResponseCode(t, http.StatusForbidden, request(t, 2, http.MethodPost, "/v1/users/3/restore"))
+	})
+
+	t.Run("should not let admins restore their peers", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, store.MockAdminID, http.MethodPost, fmt.Sprintf("/v1/users/%d/restore", store.MockAdminID)))
+	})
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/high-la/gopher-social/internal/auth"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+// Purposes of the restricted tokens issued during a two-factor login.
+const (
+	purposeTwoFactorChallenge  = "2fa_challenge"
+	purposeTwoFactorEnrollment = "2fa_enrollment"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int64  `json:"expires_in"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type VerifyTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode   string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// verifyTwoFactorHandler godoc
+//
+//	@Summary		Completes a two-factor login
+//	@Description	Exchanges the challenge token returned by /authentication/token and a TOTP or recovery code for an access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyTwoFactorPayload	true	"Challenge and code"
+//	@Success		201		{object}	AuthTokens				"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/verify [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload VerifyTwoFactorPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ip := clientIP(r)
+
+	// 01. the challenge must be a live, unused challenge token
+	jwtToken, err := app.authenticator.ValidateToken(payload.ChallengeToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+
+	if purpose, _ := claims["purpose"].(string); purpose != purposeTwoFactorChallenge {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("not a two-factor challenge token"))
+		return
+	}
+
+	userID, err := userIDFromClaims(claims)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	revoked, err := app.isTokenRevoked(ctx, claims, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revoked {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("challenge token has already been used"))
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	// 02. codes are guessed like passwords, the same lockout applies
+	locked, err := app.isLoginLocked(ctx, user.Email, ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if locked {
+		app.logger.Warnw("two-factor attempt while locked out", "user_id", user.ID, "ip", ip)
+		app.loginLockedResponse(w, r, strconv.Itoa(int(app.config.auth.lockout.window.Seconds())))
+		return
+	}
+
+	totp, err := app.getEnabledTOTP(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("two-factor authentication is not enabled"))
+		return
+	}
+
+	// 03. check the code
+	if payload.Code != "" {
+		err = app.useTOTPCode(ctx, totp, payload.Code)
+	} else {
+		err = app.store.TwoFactor.UseRecoveryCode(ctx, user.ID, normalizeRecoveryCode(payload.RecoveryCode))
+		if err == nil {
+			app.logger.Warnw("recovery code used", "user_id", user.ID, "ip", ip)
+		}
+	}
+	if err != nil {
+		switch err {
+		case store.ErrNotFound, store.ErrConflict, errInvalidTOTPCode:
+			app.recordLoginAttempt(ctx, user.Email, ip, false, "invalid two-factor code")
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// 04. the challenge is single use
+	err = app.revokeToken(ctx, claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.recordLoginAttempt(ctx, user.Email, ip, true, "")
+
+	tokens, err := app.issueTokens(r, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusCreated, tokens)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// enrollTwoFactorHandler godoc
+//
+//	@Summary		Starts a two-factor enrollment
+//	@Description	Generates a TOTP secret and its provisioning URI. Enrollment is completed by confirming a code
+//	@Tags			users
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa/enroll [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+
+	user := getUserFromContext(r)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.TwoFactor.Enroll(r.Context(), user.ID, secret)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, fmt.Errorf("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, app.config.auth.twoFactor.issuer, user.Email),
+	}
+
+	err = app.jsonResponse(w, http.StatusCreated, enrollment)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// confirmTwoFactorHandler godoc
+//
+//	@Summary		Confirms a two-factor enrollment
+//	@Description	Enables two-factor authentication with a code of the enrolled secret and returns single-use recovery codes, shown only once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa/confirm [post]
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload TwoFactorCodePayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	totp, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("two-factor enrollment has not been started"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if totp.Enabled {
+		app.conflictResponse(w, r, fmt.Errorf("two-factor authentication is already enabled"))
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidTOTPCode)
+		return
+	}
+
+	plainCodes, hashedCodes, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.TwoFactor.Enable(ctx, user.ID, step, hashedCodes)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, fmt.Errorf("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("two-factor authentication enabled", "user_id", user.ID)
+
+	err = app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: plainCodes})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// disableTwoFactorHandler godoc
+//
+//	@Summary		Disables two-factor authentication
+//	@Description	Disables two-factor authentication after checking a current code. Users whose role requires it cannot disable it
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [delete]
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload TwoFactorCodePayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	required, err := app.isTwoFactorRequired(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if required {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	totp, err := app.getEnabledTOTP(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp == nil {
+		app.notFoundResponse(w, r, fmt.Errorf("two-factor authentication is not enabled"))
+		return
+	}
+
+	err = app.useTOTPCode(ctx, totp, payload.Code)
+	if err != nil {
+		switch err {
+		case store.ErrConflict, errInvalidTOTPCode:
+			app.badRequestResponse(w, r, errInvalidTOTPCode)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.TwoFactor.Disable(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Warnw("two-factor authentication disabled", "user_id", user.ID, "ip", clientIP(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errInvalidTOTPCode = errors.New("invalid two-factor code")
+
+// useTOTPCode checks the code and spends its time step, so that a code
+// cannot be replayed.
+func (app *application) useTOTPCode(ctx context.Context, totp *store.TOTP, code string) error {
+
+	step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return errInvalidTOTPCode
+	}
+
+	return app.store.TwoFactor.UseStep(ctx, totp.UserID, step)
+}
+
+// getEnabledTOTP returns nil when the user has not completed an enrollment.
+func (app *application) getEnabledTOTP(ctx context.Context, userID int64) (*store.TOTP, error) {
+
+	totp, err := app.store.TwoFactor.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !totp.Enabled {
+		return nil, nil
+	}
+
+	return totp, nil
+}
+
+// isTwoFactorRequired reports whether the role of the user is at or above
+// the role that must use two-factor authentication.
+func (app *application) isTwoFactorRequired(ctx context.Context, user *store.User) (bool, error) {
+
+	requiredRole := app.config.auth.twoFactor.requiredRole
+	if requiredRole == "" {
+		return false, nil
+	}
+
+	return app.checkRolePrecedence(ctx, user, requiredRole)
+}
+
+func (app *application) twoFactorChallengeResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+
+	expiry := app.config.auth.twoFactor.challengeExpiry
+
+	token, err := app.generateToken(user.ID, expiry, jwt.MapClaims{"purpose": purposeTwoFactorChallenge})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("two-factor challenge issued", "user_id", user.ID, "ip", clientIP(r))
+
+	challenge := TwoFactorChallenge{
+		ChallengeToken: token,
+		ExpiresIn:      int64(expiry.Seconds()),
+	}
+
+	err = app.jsonResponse(w, http.StatusAccepted, challenge)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// twoFactorEnrollmentResponse logs in a user who must enroll first with a
+// token only accepted by the two-factor routes, and no refresh token.
+func (app *application) twoFactorEnrollmentResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+
+	token, err := app.generateToken(user.ID, app.config.auth.token.expiry, jwt.MapClaims{"purpose": purposeTwoFactorEnrollment})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("two-factor enrollment required", "user_id", user.ID)
+
+	tokens := AuthTokens{
+		AccessToken:                 token,
+		ExpiresIn:                   int64(app.config.auth.token.expiry.Seconds()),
+		TwoFactorEnrollmentRequired: true,
+	}
+
+	err = app.jsonResponse(w, http.StatusCreated, tokens)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// generateRecoveryCodes returns the codes to show to the user and their
+// hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	plain := make([]string, 0, recoveryCodeCount)
+	hashed := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 5)
+
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+
+		plain = append(plain, code[:4]+"-"+code[4:])
+		hashed = append(hashed, hashToken(code))
+	}
+
+	return plain, hashed, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
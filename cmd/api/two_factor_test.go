@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/auth"
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestTwoFactorLogin(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token:     tokenConfig{expiry: time.Minute},
+			twoFactor: twoFactorConfig{challengeExpiry: time.Minute},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store.TwoFactor.(*store.MockTwoFactorStore).TOTP = &store.TOTP{UserID: 1, Secret: secret, Enabled: true}
+
+	login := func(t *testing.T) string {
+		body := `{"email": "gopher@example.com", "password": "` + store.MockPassword + `"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+
+		var res struct {
+			Data TwoFactorChallenge `json:"data"`
+		}
+
+		err = json.NewDecoder(rr.Body).Decode(&res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Data.ChallengeToken
+	}
+
+	verify := func(t *testing.T, challenge, code string) int {
+		body := `{"challenge_token": "` + challenge + `", "code": "` + code + `"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/2fa/verify", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return executeRequest(req, mux).Code
+	}
+
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not accept the challenge token as an access token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/tokens", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+login(t))
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should reject an invalid code", func(t *testing.T) {
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, verify(t, login(t), wrong))
+	})
+
+	t.Run("should issue tokens for a valid code", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, verify(t, login(t), code))
+	})
+
+	t.Run("should not accept the same code twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, verify(t, login(t), code))
+	})
+}
+
+func TestTwoFactorRequiredEnrollment(t *testing.T) {
+	cfg := config{
+		auth: authConfig{
+			token: tokenConfig{expiry: time.Minute},
+			twoFactor: twoFactorConfig{
+				issuer:       "GopherSocial",
+				requiredRole: "user",
+			},
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	body := `{"email": "gopher@example.com", "password": "` + store.MockPassword + `"}`
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+
+	var res struct {
+		Data AuthTokens `json:"data"`
+	}
+
+	err = json.NewDecoder(rr.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Data.TwoFactorEnrollmentRequired || res.Data.RefreshToken != "" {
+		t.Fatalf("expected an enrollment-only token, got %+v", res.Data)
+	}
+
+	t.Run("should only allow the enrollment routes", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/tokens", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+res.Data.AccessToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should start the enrollment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/2fa/enroll", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+res.Data.AccessToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	})
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/high-la/gopher-social/internal/mailer"
 	"github.com/high-la/gopher-social/internal/store"
 )
 
@@ -46,6 +50,102 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ResendActivation godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Rotates the invitation token of an inactive account and emails it again. The response is the same whether the email is known or not
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Activation email resent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+
+	var payload ResendActivationPayload
+
+	err := readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = Validate.Struct(payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if app.config.activationRateLimiter.Enabled {
+		if allow, retryAfter := app.activationRateLimiter.Allow(strings.ToLower(payload.Email)); !allow {
+			app.rateLimiterExceededResponse(w, r, retryAfter.String())
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetInactiveByEmail(ctx, payload.Email)
+	switch err {
+	case nil:
+		err = app.resendActivation(r, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	case store.ErrNotFound:
+		app.logger.Infow("activation resend requested for unknown or active email", "email", payload.Email, "ip", clientIP(r))
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.jsonResponse(w, http.StatusAccepted, "if the email belongs to an inactive account, an activation link has been sent")
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) resendActivation(r *http.Request, user *store.User) error {
+
+	plainToken := uuid.New().String()
+
+	err := app.store.Users.RotateInvitation(r.Context(), user.ID, hashToken(plainToken), app.config.mail.expiry)
+	if err != nil {
+		return err
+	}
+
+	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: activationURL,
+	}
+
+	go func() {
+		status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+		if err != nil {
+			app.logger.Errorw("error resending activation email", "user_id", user.ID, "error", err)
+			return
+		}
+
+		app.logger.Infow("Email sent", "status code", status)
+	}()
+
+	return nil
+}
+
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
@@ -92,6 +192,118 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// UserProfile is a user along with the posts they pinned.
+type UserProfile struct {
+	User        *store.User              `json:"user"`
+	PinnedPosts []store.PostWithMetadata `json:"pinned_posts"`
+}
+
+// GetUserProfile godoc
+//
+//	@Summary		Fetches a user profile with its pinned posts
+//	@Description	Fetches a user by id along with the posts they pinned, in their order, that are visible to the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	UserProfile
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/profile [get]
+func (app *application) getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	pinned, err := app.store.Posts.GetPinned(ctx, user.ID, getUserFromContext(r).ID, maxPinnedPosts)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setFeedAttachmentURLs(pinned)
+
+	err = app.jsonResponse(w, http.StatusOK, UserProfile{User: user, PinnedPosts: pinned})
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListUserPosts godoc
+//
+//	@Summary		Lists the posts of a user
+//	@Description	Lists the published posts of a user visible to the authenticated user, latest first by default and filtered like the feed. Pinned posts are flagged
+//	@Tags			users
+//	@Produce		json
+//	@Param			id		path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/posts [get]
+func (app *application) listUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	fq, err := parseFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, err := app.store.Posts.GetByUserID(ctx, userID, getUserFromContext(r).ID, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.setFeedAttachmentURLs(posts)
+
+	err = app.jsonResponse(w, http.StatusOK, posts)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 type FollowUser struct {
 	UserID int64 `json:"user_id"`
 }
@@ -2,8 +2,11 @@ package main
 
 import (
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/high-la/gopher-social/internal/ratelimiter"
 	"github.com/high-la/gopher-social/internal/store/cache"
 	"github.com/stretchr/testify/mock"
 )
@@ -37,6 +40,7 @@ func TestGetUser(t *testing.T) {
 	t.Run("should allow authenticated requests", func(t *testing.T) {
 		mockCacheStore := app.cacheStorage.Users.(*cache.MockUserStore)
 
+		mockCacheStore.On("Get", int64(42)).Return(nil, nil)
 		mockCacheStore.On("Get", int64(1)).Return(nil, nil).Twice()
 		mockCacheStore.On("Set", mock.Anything).Return(nil)
 
@@ -105,3 +109,36 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil // Reset mock expectations
 	})
 }
+
+func TestResendActivation(t *testing.T) {
+	cfg := config{
+		activationRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: 2,
+			TimeFrame:            time.Hour,
+			Enabled:              true,
+		},
+	}
+
+	app := newTestApplication(t, cfg)
+	mux := app.mount()
+
+	for i := 0; i < cfg.activationRateLimiter.RequestsPerTimeFrame+1; i++ {
+		body := `{"email": "Gopher@example.com"}`
+		if i%2 == 0 {
+			body = `{"email": "gopher@example.com"}`
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/activation/resend", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		if i < cfg.activationRateLimiter.RequestsPerTimeFrame {
+			checkResponseCode(t, http.StatusAccepted, rr.Code)
+		} else {
+			checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+		}
+	}
+}
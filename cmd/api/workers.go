@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// startWorkers launches the periodic background jobs. They stop when ctx is
+// cancelled and wg is released once they have all returned.
+func (app *application) startWorkers(ctx context.Context, wg *sync.WaitGroup) {
+
+	app.runPeriodically(ctx, wg, "invitation sweeper", app.config.sweeper.interval, app.sweepInvitations)
+	app.runPeriodically(ctx, wg, "oidc state sweeper", app.config.sweeper.interval, app.sweepOIDCStates)
+	app.runPeriodically(ctx, wg, "session sweeper", app.config.sweeper.interval, app.sweepSessions)
+	app.runPeriodically(ctx, wg, "post publisher", app.config.posts.publishInterval, app.publishScheduledPosts)
+	app.runPeriodically(ctx, wg, "trash purger", app.config.sweeper.interval, app.purgeTrash)
+	app.runPeriodically(ctx, wg, "attachment sweeper", app.config.sweeper.interval, app.sweepAttachments)
+}
+
+func (app *application) runPeriodically(ctx context.Context, wg *sync.WaitGroup, name string, interval time.Duration, job func(context.Context) error) {
+
+	if interval <= 0 {
+		app.logger.Infow("worker disabled", "worker", name)
+		return
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		app.logger.Infow("worker started", "worker", name, "interval", interval.String())
+
+		for {
+			select {
+			case <-ctx.Done():
+				app.logger.Infow("worker stopped", "worker", name)
+				return
+			case <-ticker.C:
+				err := job(ctx)
+				if err != nil && ctx.Err() == nil {
+					app.logger.Errorw("worker failed", "worker", name, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// sweepInvitations deletes expired invitations and the accounts that were
+// not activated within the grace period after their latest invitation expired.
+func (app *application) sweepInvitations(ctx context.Context) error {
+
+	invitations, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		return err
+	}
+
+	expiredBefore := time.Now().Add(-app.config.sweeper.unactivatedGracePeriod)
+
+	users, err := app.store.Users.DeleteUnactivated(ctx, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	if invitations > 0 || users > 0 {
+		app.logger.Infow("invitations swept", "expired_invitations", invitations, "unactivated_users", users)
+	}
+
+	return nil
+}
+
+// sweepOIDCStates deletes the social logins that were started but never
+// came back from the provider.
+func (app *application) sweepOIDCStates(ctx context.Context) error {
+
+	states, err := app.store.Identities.DeleteExpiredStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	if states > 0 {
+		app.logger.Infow("oidc states swept", "expired_states", states)
+	}
+
+	return nil
+}
+
+// publishScheduledPosts publishes the scheduled posts whose time has come.
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+
+	posts, err := app.store.Posts.PublishScheduled(ctx)
+	if err != nil {
+		return err
+	}
+
+	if posts > 0 {
+		app.logger.Infow("scheduled posts published", "posts", posts)
+	}
+
+	return nil
+}
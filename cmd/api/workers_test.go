@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/high-la/gopher-social/internal/store"
+)
+
+func TestPostPublisher(t *testing.T) {
+	cfg := config{
+		posts: postsConfig{publishInterval: 10 * time.Millisecond},
+	}
+
+	app := newTestApplication(t, cfg)
+
+	posts := app.store.Posts.(*store.MockPostStore)
+
+	due := time.Now().Add(-time.Minute)
+	trashedAt := time.Now()
+
+	posts.Scheduled = []*store.Post{
+		{ID: 1, Status: store.PostStatusScheduled, PublishAt: &due, Version: 1},
+		{ID: 2, Status: store.PostStatusScheduled, PublishAt: &due, Version: 1, DeletedAt: &trashedAt},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	app.runPeriodically(ctx, &wg, "post publisher", app.config.posts.publishInterval, app.publishScheduledPosts)
+
+	t.Run("should publish scheduled posts on every tick", func(t *testing.T) {
+		deadline := time.Now().Add(time.Second)
+
+		for posts.PublishRuns.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if runs := posts.PublishRuns.Load(); runs < 2 {
+			t.Errorf("expected at least 2 runs, got %d", runs)
+		}
+	})
+
+	t.Run("should publish due posts once", func(t *testing.T) {
+		post := posts.ScheduledPost(0)
+
+		if post.Status != store.PostStatusPublished || post.Version != 2 {
+			t.Errorf("expected the post published at version 2, got %q at version %d", post.Status, post.Version)
+		}
+	})
+
+	t.Run("should leave scheduled posts in the trash", func(t *testing.T) {
+		post := posts.ScheduledPost(1)
+
+		if post.Status != store.PostStatusScheduled || post.Version != 1 {
+			t.Errorf("expected the trashed post left scheduled at version 1, got %q at version %d", post.Status, post.Version)
+		}
+	})
+
+	t.Run("should stop when cancelled", func(t *testing.T) {
+		cancel()
+		wg.Wait()
+
+		runs := posts.PublishRuns.Load()
+		time.Sleep(3 * cfg.posts.publishInterval)
+
+		if posts.PublishRuns.Load() != runs {
+			t.Errorf("expected no run after the worker stopped")
+		}
+	})
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+
+    id bigserial PRIMARY KEY,
+    email citext NOT NULL,
+    ip_address varchar(45) NOT NULL,
+    succeeded boolean NOT NULL DEFAULT FALSE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts (email, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address, created_at);
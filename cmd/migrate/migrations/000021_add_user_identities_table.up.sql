@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email citext,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+
+-- pending authorization requests, consumed by the callback
+CREATE TABLE IF NOT EXISTS oidc_states (
+
+    state bytea PRIMARY KEY,
+    provider varchar(50) NOT NULL,
+    nonce varchar(255) NOT NULL,
+    code_verifier varchar(255) NOT NULL,
+    -- set when an authenticated user links a provider
+    user_id bigint,
+    expiry timestamp(0) with time zone NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- one session per login, its id is the family id of its refresh tokens
+CREATE TABLE IF NOT EXISTS sessions (
+
+    id uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    user_agent text NOT NULL DEFAULT '',
+    ip_address varchar(45) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    revoked_at timestamp(0) with time zone,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
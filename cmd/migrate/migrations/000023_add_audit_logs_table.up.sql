@@ -0,0 +1,19 @@
+-- actions taken by a user on behalf of another, e.g. an admin impersonating
+CREATE TABLE IF NOT EXISTS audit_logs (
+
+    id bigserial PRIMARY KEY,
+    actor_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    action varchar(100) NOT NULL,
+    method varchar(10) NOT NULL DEFAULT '',
+    path text NOT NULL DEFAULT '',
+    status int NOT NULL DEFAULT 0,
+    ip_address varchar(45) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_post_id_id;
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_user;
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_post;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
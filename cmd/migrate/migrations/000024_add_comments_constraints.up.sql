@@ -0,0 +1,21 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+-- comments left behind by deleted posts and users
+DELETE FROM comments c
+WHERE
+    NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id) OR
+    NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post FOREIGN KEY(post_id)
+REFERENCES posts(id)
+ON DELETE CASCADE;
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_user FOREIGN KEY(user_id)
+REFERENCES users(id)
+ON DELETE CASCADE;
+
+-- comments are paginated by id within a post
+DROP INDEX IF EXISTS idx_comments_post_id;
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_id ON comments (post_id, id);
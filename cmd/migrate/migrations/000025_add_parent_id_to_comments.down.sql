@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_parent_id_id;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_parent;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS depth;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
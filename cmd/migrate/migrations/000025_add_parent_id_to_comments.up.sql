@@ -0,0 +1,12 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id bigint;
+-- 0 for comments on the post, 1 for their replies and so on
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS depth int NOT NULL DEFAULT 0;
+-- deleted comments with replies are kept as placeholders
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_parent FOREIGN KEY(parent_id)
+REFERENCES comments(id)
+ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id_id ON comments (parent_id, id);
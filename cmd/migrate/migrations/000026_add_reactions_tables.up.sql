@@ -0,0 +1,26 @@
+-- one reaction per user on a post or a comment, kind is one of store.ReactionKinds
+CREATE TABLE IF NOT EXISTS post_reactions (
+
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    kind varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, user_id),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS comment_reactions (
+
+    comment_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    kind varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (comment_id, user_id),
+
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
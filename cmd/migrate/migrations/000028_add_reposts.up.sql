@@ -0,0 +1,22 @@
+-- quote posts reference the original, which can be deleted from under them
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quoted_post_id bigint;
+
+ALTER TABLE posts
+ADD CONSTRAINT fk_posts_quoted_post FOREIGN KEY(quoted_post_id)
+REFERENCES posts(id)
+ON DELETE SET NULL;
+
+-- plain reshares, gone with the original
+CREATE TABLE IF NOT EXISTS reposts (
+
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, post_id),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reposts_post_id ON reposts (post_id);
@@ -0,0 +1,33 @@
+-- entities parsed from the title or the content of posts, offsets are in characters
+CREATE TABLE IF NOT EXISTS post_mentions (
+
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    field varchar(10) NOT NULL,
+    start_offset int NOT NULL,
+    end_offset int NOT NULL,
+    -- the username as written
+    text varchar(20) NOT NULL,
+
+    PRIMARY KEY (post_id, field, start_offset),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_mentions_user_id ON post_mentions (user_id);
+
+CREATE TABLE IF NOT EXISTS post_hashtags (
+
+    post_id bigint NOT NULL,
+    tag varchar(50) NOT NULL,
+    field varchar(10) NOT NULL,
+    start_offset int NOT NULL,
+    end_offset int NOT NULL,
+
+    PRIMARY KEY (post_id, field, start_offset),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_tag ON post_hashtags (tag);
@@ -0,0 +1,2 @@
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS chk_posts_visibility;
+ALTER TABLE posts DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS visibility varchar(20) NOT NULL DEFAULT 'public';
+
+ALTER TABLE posts
+ADD CONSTRAINT chk_posts_visibility CHECK (visibility IN ('public', 'followers', 'private'));
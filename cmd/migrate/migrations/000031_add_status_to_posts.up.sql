@@ -0,0 +1,11 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'published';
+
+ALTER TABLE posts
+ADD CONSTRAINT chk_posts_status CHECK (status IN ('draft', 'scheduled', 'published'));
+
+-- when the post was or will be published, null for drafts
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+UPDATE posts SET publish_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at) WHERE status = 'scheduled';
@@ -0,0 +1,21 @@
+-- every version of a post, never updated
+CREATE TABLE IF NOT EXISTS post_revisions (
+
+    post_id bigint NOT NULL,
+    version int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    tags varchar(100) [],
+    -- the author or the moderator who made the change
+    editor_id bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, version),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- the current text of existing posts, whose editor is only known when never edited
+INSERT INTO post_revisions (post_id, version, title, content, tags, editor_id, created_at)
+SELECT id, version, title, content, tags, CASE WHEN version = 0 THEN user_id END, updated_at FROM posts;
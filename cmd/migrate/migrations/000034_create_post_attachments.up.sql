@@ -0,0 +1,19 @@
+-- images attached to posts, whose blobs are in the media storage
+CREATE TABLE IF NOT EXISTS post_attachments (
+
+    id bigserial PRIMARY KEY,
+    -- null once detached, until the sweeper deletes the blobs
+    post_id bigint,
+    key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    content_type varchar(50) NOT NULL,
+    size bigint NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_attachments_post_id ON post_attachments (post_id, id);
+CREATE INDEX IF NOT EXISTS idx_post_attachments_detached ON post_attachments (id) WHERE post_id IS NULL;
@@ -0,0 +1,15 @@
+-- the posts users pin on their profile, only their own ones, in the order of position
+CREATE TABLE IF NOT EXISTS pinned_posts (
+
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    position int NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, post_id),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_posts_post_id ON pinned_posts (post_id);
@@ -0,0 +1,3 @@
+DELETE FROM revoked_tokens WHERE jti !~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$';
+
+ALTER TABLE IF EXISTS revoked_tokens ALTER COLUMN jti TYPE uuid USING jti::uuid;
@@ -0,0 +1,2 @@
+-- jti comes from the token, any string must be looked up without a cast error
+ALTER TABLE IF EXISTS revoked_tokens ALTER COLUMN jti TYPE text;
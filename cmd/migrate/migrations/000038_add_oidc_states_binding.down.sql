@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS oidc_states
+DROP COLUMN binding;
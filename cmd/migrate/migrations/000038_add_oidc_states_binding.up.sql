@@ -0,0 +1,5 @@
+-- pending requests predate the binding and could never complete
+DELETE FROM oidc_states;
+
+ALTER TABLE IF EXISTS oidc_states
+ADD COLUMN binding bytea NOT NULL;
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AsymmetricJWTAuthenticator signs tokens with an RSA (RS256) or Ed25519
+// (EdDSA) private key and verifies them with any of its known public keys.
+//
+// Keys are rotated by promoting a new signing key and moving the previous
+// one to the verification keys until the tokens it signed have expired.
+type AsymmetricJWTAuthenticator struct {
+	signing *jwtKey
+	// verification keys by kid, the signing key included
+	keys map[string]*jwtKey
+	kids []string
+	aud  string
+	iss  string
+}
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+func NewAsymmetricJWTAuthenticator(signingKeyFile string, verificationKeyFiles []string, aud, iss string) (*AsymmetricJWTAuthenticator, error) {
+
+	signing, err := loadKey(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if signing.private == nil {
+		return nil, fmt.Errorf("signing key %s is not a private key", signingKeyFile)
+	}
+
+	a := &AsymmetricJWTAuthenticator{
+		signing: signing,
+		keys:    map[string]*jwtKey{signing.kid: signing},
+		kids:    []string{signing.kid},
+		aud:     aud,
+		iss:     iss,
+	}
+
+	for _, file := range verificationKeyFiles {
+		key, err := loadKey(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := a.keys[key.kid]; ok {
+			continue
+		}
+
+		a.keys[key.kid] = key
+		a.kids = append(a.kids, key.kid)
+	}
+
+	return a, nil
+}
+
+func (a *AsymmetricJWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+
+	token := jwt.NewWithClaims(a.signing.method, claims)
+	token.Header["kid"] = a.signing.kid
+
+	tokenString, err := token.SignedString(a.signing.private)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *AsymmetricJWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+
+	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
+
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		return key.public, nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+	)
+}
+
+// JWKS returns every verification key, the signing one included.
+func (a *AsymmetricJWTAuthenticator) JWKS() JWKS {
+
+	set := JWKS{Keys: []JWK{}}
+
+	for _, kid := range a.kids {
+		set.Keys = append(set.Keys, a.keys[kid].jwk())
+	}
+
+	return set
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *jwtKey) jwk() JWK {
+
+	jwk := JWK{
+		Use: "sig",
+		Alg: k.method.Alg(),
+		Kid: k.kid,
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// loadKey reads a PEM encoded RSA or Ed25519 key. Private keys can be used
+// for signing and verifying, public keys only for verifying.
+func loadKey(file string) (*jwtKey, error) {
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+
+	var parsed any
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	key := &jwtKey{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T in %s", parsed, file)
+	}
+
+	key.kid, err = thumbprint(key.public)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the key id.
+func thumbprint(public crypto.PublicKey) (string, error) {
+
+	var members any
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		}
+	case ed25519.PublicKey:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{
+			Crv: "Ed25519",
+			Kty: "OKP",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return "", errors.New("unsupported public key type")
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "key.pem")
+
+	err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func newRSAKeyFiles(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM(t, "PRIVATE KEY", private), writePEM(t, "PUBLIC KEY", public)
+}
+
+func newEd25519KeyFile(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM(t, "PRIVATE KEY", private)
+}
+
+func testClaimsFor(aud string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 42,
+		"aud": aud,
+		"iss": aud,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAsymmetricJWTAuthenticator(t *testing.T) {
+	oldPrivate, oldPublic := newRSAKeyFiles(t)
+	newPrivate := newEd25519KeyFile(t)
+
+	old, err := NewAsymmetricJWTAuthenticator(oldPrivate, nil, "test-aud", "test-aud")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewAsymmetricJWTAuthenticator(newPrivate, []string{oldPublic}, "test-aud", "test-aud")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should validate its own tokens", func(t *testing.T) {
+		token, err := rotated.GenerateToken(testClaimsFor("test-aud"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := rotated.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["kid"] != rotated.signing.kid {
+			t.Errorf("expected kid %s, got %v", rotated.signing.kid, parsed.Header["kid"])
+		}
+	})
+
+	t.Run("should keep validating tokens of a rotated key", func(t *testing.T) {
+		token, err := old.GenerateToken(testClaimsFor("test-aud"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rotated.ValidateToken(token)
+		if err != nil {
+			t.Errorf("expected token of the previous key to be valid, got %v", err)
+		}
+	})
+
+	t.Run("should reject tokens of unknown keys", func(t *testing.T) {
+		token, err := rotated.GenerateToken(testClaimsFor("test-aud"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = old.ValidateToken(token)
+		if err == nil {
+			t.Error("expected token of an unknown key to be rejected")
+		}
+	})
+
+	t.Run("should publish every verification key", func(t *testing.T) {
+		set := rotated.JWKS()
+
+		if len(set.Keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(set.Keys))
+		}
+
+		if set.Keys[0].Kty != "OKP" || set.Keys[1].Kty != "RSA" {
+			t.Errorf("unexpected key types %s, %s", set.Keys[0].Kty, set.Keys[1].Kty)
+		}
+	})
+}
@@ -6,3 +6,9 @@ type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
 }
+
+// JWKSProvider is implemented by the authenticators whose tokens can be
+// verified by third parties with public keys.
+type JWKSProvider interface {
+	JWKS() JWKS
+}
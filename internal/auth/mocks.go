@@ -18,9 +18,14 @@ var testClaims = jwt.MapClaims{
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
+// GenerateToken signs the given claims, or claims for user 42 when nil.
 func (a *TestAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims)
+	if claims == nil {
+		claims = testClaims
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, _ := token.SignedString([]byte(secret))
 
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B SHA1 vectors, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, v := range vectors {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != v.code {
+			t.Errorf("at %d: expected %s, got %s", v.unix, v.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	t.Run("should accept codes within the allowed drift", func(t *testing.T) {
+		previous, err := TOTPCode(secret, TOTPStep(now)-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		step, ok := ValidateTOTP(secret, previous, now)
+		if !ok {
+			t.Fatal("expected code of the previous period to be accepted")
+		}
+
+		if step != TOTPStep(now)-1 {
+			t.Errorf("expected step %d, got %d", TOTPStep(now)-1, step)
+		}
+	})
+
+	t.Run("should reject codes outside the allowed drift", func(t *testing.T) {
+		old, err := TOTPCode(secret, TOTPStep(now)-3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := ValidateTOTP(secret, old, now); ok {
+			t.Error("expected stale code to be rejected")
+		}
+	})
+}
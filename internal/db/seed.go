@@ -152,6 +152,8 @@ func generatePosts(num int, users []*store.User) []*store.Post {
 				tags[rand.Intn(len(tags))],
 				tags[rand.Intn(len(tags))],
 			},
+			Visibility: store.VisibilityPublic,
+			Status:     store.PostStatusPublished,
 		}
 	}
 
@@ -0,0 +1,158 @@
+// Package diff compares two versions of a piece of text word by word.
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Edit is a run of text kept, inserted or deleted to go from one version to
+// the other.
+type Edit struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxWords is the most words and runs of spaces the longest allowed post
+// content, 1000 characters, is split into.
+const maxWords = 1000
+
+// maxCells bounds the size of the LCS table so that two posts of the longest
+// allowed content always fit. Longer texts have their changed middle
+// replaced as a whole.
+const maxCells = (maxWords + 1) * (maxWords + 1)
+
+// Words returns the edits turning a into b. Words and the spaces between
+// them are compared as a whole, and consecutive edits of the same kind are
+// merged, so joining the text of the edits other than deletions gives b.
+func Words(a, b string) []Edit {
+
+	from, to := split(a), split(b)
+
+	edits := []Edit{}
+
+	// words of a run are gathered before the edit is made
+	var op string
+	var run strings.Builder
+
+	flush := func() {
+		if run.Len() > 0 {
+			edits = append(edits, Edit{Op: op, Text: run.String()})
+			run.Reset()
+		}
+	}
+
+	add := func(next, text string) {
+		if next != op {
+			flush()
+			op = next
+		}
+		run.WriteString(text)
+	}
+
+	// the common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		add(OpEqual, from[prefix])
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	for _, e := range middle(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]) {
+		add(e.Op, e.Text)
+	}
+
+	for _, word := range from[len(from)-suffix:] {
+		add(OpEqual, word)
+	}
+
+	flush()
+
+	return edits
+}
+
+// middle diffs the words between the common prefix and suffix.
+func middle(from, to []string) []Edit {
+
+	edits := []Edit{}
+
+	if (len(from)+1)*(len(to)+1) > maxCells {
+		for _, word := range from {
+			edits = append(edits, Edit{Op: OpDelete, Text: word})
+		}
+		for _, word := range to {
+			edits = append(edits, Edit{Op: OpInsert, Text: word})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			edits = append(edits, Edit{Op: OpEqual, Text: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, Edit{Op: OpDelete, Text: from[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: OpInsert, Text: to[j]})
+			j++
+		}
+	}
+
+	for ; i < len(from); i++ {
+		edits = append(edits, Edit{Op: OpDelete, Text: from[i]})
+	}
+	for ; j < len(to); j++ {
+		edits = append(edits, Edit{Op: OpInsert, Text: to[j]})
+	}
+
+	return edits
+}
+
+// split cuts the text into words and runs of spaces.
+func split(text string) []string {
+
+	tokens := []string{}
+	start := 0
+	runes := []rune(text)
+
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || unicode.IsSpace(runes[i]) != unicode.IsSpace(runes[start]) {
+			tokens = append(tokens, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	return tokens
+}
@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{
+			name: "same text",
+			a:    "hello gophers",
+			b:    "hello gophers",
+			want: []Edit{{Op: OpEqual, Text: "hello gophers"}},
+		},
+		{
+			name: "word replaced",
+			a:    "hello gophers, welcome",
+			b:    "hello rustaceans, welcome",
+			want: []Edit{
+				{Op: OpEqual, Text: "hello "},
+				{Op: OpDelete, Text: "gophers,"},
+				{Op: OpInsert, Text: "rustaceans,"},
+				{Op: OpEqual, Text: " welcome"},
+			},
+		},
+		{
+			name: "words appended",
+			a:    "hello",
+			b:    "hello there you",
+			want: []Edit{
+				{Op: OpEqual, Text: "hello"},
+				{Op: OpInsert, Text: " there you"},
+			},
+		},
+		{
+			name: "from nothing",
+			a:    "",
+			b:    "hi",
+			want: []Edit{{Op: OpInsert, Text: "hi"}},
+		},
+		{
+			name: "to nothing",
+			a:    "bye  now",
+			b:    "",
+			want: []Edit{{Op: OpDelete, Text: "bye  now"}},
+		},
+		{
+			name: "nothing",
+			want: []Edit{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %+v, want %+v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordsRebuildsBothVersions(t *testing.T) {
+	a := "the quick brown fox\njumps over the lazy dog"
+	b := "the slow brown fox\n\nwalks over the dog"
+
+	var from, to strings.Builder
+
+	for _, e := range Words(a, b) {
+		if e.Op != OpInsert {
+			from.WriteString(e.Text)
+		}
+		if e.Op != OpDelete {
+			to.WriteString(e.Text)
+		}
+	}
+
+	if from.String() != a || to.String() != b {
+		t.Errorf("edits rebuild %q and %q, want %q and %q", from.String(), to.String(), a, b)
+	}
+}
+
+func TestWordsDiffsLongestPosts(t *testing.T) {
+	// maxWords words and spaces each, with no common prefix or suffix
+	a := strings.Repeat(" a", maxWords/2)
+	b := strings.Repeat("a ", maxWords/2)
+
+	got := Words(a, b)
+
+	want := []Edit{
+		{Op: OpDelete, Text: " "},
+		{Op: OpEqual, Text: "a" + strings.Repeat(" a", maxWords/2-1)},
+		{Op: OpInsert, Text: " "},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the longest posts to be diffed word by word, got %d edits", len(got))
+	}
+}
+
+func TestWordsBoundsLongTexts(t *testing.T) {
+	a := "same start " + strings.Repeat("a ", 600) + "same end"
+	b := "same start " + strings.Repeat("b ", 600) + "same end"
+
+	got := Words(a, b)
+
+	want := []Edit{
+		{Op: OpEqual, Text: "same start "},
+		{Op: OpDelete, Text: strings.Repeat("a ", 599) + "a"},
+		{Op: OpInsert, Text: strings.Repeat("b ", 599) + "b"},
+		{Op: OpEqual, Text: " same end"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the changed middle to be replaced as a whole, got %d edits", len(got))
+	}
+}
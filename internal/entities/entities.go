@@ -0,0 +1,108 @@
+// Package entities finds the @mentions and #hashtags in a piece of text.
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	TypeMention = "mention"
+	TypeHashtag = "hashtag"
+)
+
+const (
+	// usernames are varchar(20)
+	maxMentionLength = 20
+	maxHashtagLength = 50
+)
+
+// Entity is a mention or a hashtag. Start and End are offsets in characters
+// (code points, not bytes) of the whole entity, sigil included, End being
+// exclusive. Text is the username as written, or the hashtag in lower case,
+// without the sigil.
+type Entity struct {
+	Type  string
+	Start int
+	End   int
+	Text  string
+}
+
+// Parse returns the entities of the text in order. A sigil only starts an
+// entity at the beginning of a word, so emails and anchors like a#b are
+// left alone.
+func Parse(text string) []Entity {
+
+	runes := []rune(text)
+	found := []Entity{}
+
+	for i := 0; i < len(runes); i++ {
+
+		var typ string
+		switch runes[i] {
+		case '@':
+			typ = TypeMention
+		case '#':
+			typ = TypeHashtag
+		default:
+			continue
+		}
+
+		if i > 0 && (isWordRune(runes[i-1]) || runes[i-1] == '@' || runes[i-1] == '#') {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		word := string(runes[i+1 : end])
+
+		if typ == TypeMention && isMention(word) {
+			found = append(found, Entity{Type: typ, Start: i, End: end, Text: word})
+		}
+
+		if typ == TypeHashtag && isHashtag(word) {
+			found = append(found, Entity{Type: typ, Start: i, End: end, Text: strings.ToLower(word)})
+		}
+
+		i = end - 1
+	}
+
+	return found
+}
+
+// Hashtags returns the distinct hashtags of the entities.
+func Hashtags(found []Entity) []string {
+
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, e := range found {
+		if e.Type == TypeHashtag && !seen[e.Text] {
+			seen[e.Text] = true
+			tags = append(tags, e.Text)
+		}
+	}
+
+	return tags
+}
+
+func isWordRune(r rune) bool {
+
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isMention(word string) bool {
+
+	n := len([]rune(word))
+	return n > 0 && n <= maxMentionLength
+}
+
+// isHashtag refuses words made of digits only, like in "issue #42".
+func isHashtag(word string) bool {
+
+	n := len([]rune(word))
+	return n > 0 && n <= maxHashtagLength && strings.IndexFunc(word, unicode.IsLetter) >= 0
+}
@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{
+			name: "mentions and hashtags",
+			text: "hi @gopher, meet #GoLang",
+			want: []Entity{
+				{Type: TypeMention, Start: 3, End: 10, Text: "gopher"},
+				{Type: TypeHashtag, Start: 17, End: 24, Text: "golang"},
+			},
+		},
+		{
+			name: "offsets in characters",
+			text: "¡olé! #café @josé",
+			want: []Entity{
+				{Type: TypeHashtag, Start: 6, End: 11, Text: "café"},
+				{Type: TypeMention, Start: 12, End: 17, Text: "josé"},
+			},
+		},
+		{
+			name: "not at the beginning of a word",
+			text: "mail me@example.com or see page#top",
+			want: []Entity{},
+		},
+		{
+			name: "numbers and lone sigils",
+			text: "fixes #42 @ # ##go",
+			want: []Entity{},
+		},
+		{
+			name: "too long to be a username",
+			text: "@abcdefghijklmnopqrstuvwxyz",
+			want: []Entity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags(Parse("#Go and #go, @gopher and #rust"))
+	want := []string{"go", "rust"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashtags() = %v, want %v", got, want)
+	}
+}
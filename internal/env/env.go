@@ -14,7 +14,7 @@ func GetInt(key string, fallback int) int {
 		return fallback
 	}
 
-	intVal, err := strconv.Atoi(os.Getenv(val))
+	intVal, err := strconv.Atoi(val)
 	if err != nil {
 		return fallback
 	}
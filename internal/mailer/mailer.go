@@ -3,9 +3,11 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "GopherSocial"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.html"
+	FromName              = "GopherSocial"
+	maxRetries            = 3
+	UserWelcomeTemplate   = "user_invitation.html"
+	PasswordResetTemplate = "password_reset.html"
+	MagicLinkTemplate     = "magic_link.html"
 )
 
 //go:embed "templates"
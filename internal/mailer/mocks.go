@@ -0,0 +1,7 @@
+package mailer
+
+type TestMailer struct{}
+
+func (m *TestMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return 200, nil
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// MaxFrames bounds the number of frames of an animated GIF.
+const MaxFrames = 1000
+
+// gifPixels walks the blocks of a GIF without decoding them and returns the
+// number of frames and the pixels they add up to, what decoding them all
+// allocates. ok is false when the structure is broken.
+func gifPixels(data []byte) (frames, pixels int, ok bool) {
+
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return 0, 0, false
+	}
+
+	i := 13
+	// global color table
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x3B: // trailer
+			return frames, pixels, true
+
+		case 0x21: // extension: label, then sub-blocks
+			if i+2 > len(data) {
+				return 0, 0, false
+			}
+			i = skipSubBlocks(data, i+2)
+
+		case 0x2C: // image descriptor
+			if i+10 > len(data) {
+				return 0, 0, false
+			}
+			w := int(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int(binary.LittleEndian.Uint16(data[i+7:]))
+			packed := data[i+9]
+
+			frames++
+			pixels += w * h
+
+			i += 10
+			// local color table
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data sub-blocks
+			i = skipSubBlocks(data, i+1)
+
+		default:
+			return 0, 0, false
+		}
+
+		if i < 0 {
+			return 0, 0, false
+		}
+	}
+
+	// a missing trailer is tolerated by the decoder
+	return frames, pixels, true
+}
+
+// skipSubBlocks returns the offset past the sub-blocks starting at i, or -1
+// when they run past the data.
+func skipSubBlocks(data []byte, i int) int {
+
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i
+		}
+		i += size
+	}
+
+	return -1
+}
@@ -0,0 +1,185 @@
+// Package media checks uploaded images and prepares them for serving:
+// re-encoding drops their metadata, EXIF included, and thumbnails are
+// generated alongside.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels bounds the decoded size of an image, a small file being able
+// to claim huge dimensions. The frames of a GIF count together.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+// Extensions maps the accepted content types to their file extension.
+var Extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process sniffs the upload's type, whatever the client claimed, and
+// re-encodes it. The thumbnail fits in a thumbnailSize square.
+func Process(data []byte, thumbnailSize int) (*Image, error) {
+
+	contentType := http.DetectContentType(data)
+	if _, ok := Extensions[contentType]; !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img := &Image{ContentType: contentType, ThumbnailContentType: "image/png"}
+
+	var first image.Image
+	var buf bytes.Buffer
+
+	switch contentType {
+	case "image/gif":
+		// every frame is decoded, so they are bounded before that
+		frames, pixels, ok := gifPixels(data)
+		if !ok {
+			return nil, ErrInvalidImage
+		}
+		if frames > MaxFrames {
+			return nil, ErrTooManyFrames
+		}
+		if pixels > MaxPixels {
+			return nil, ErrTooManyPixels
+		}
+
+		// keep the animation, dropping comments and extensions
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return nil, err
+		}
+		first = anim.Image[0]
+		img.Width, img.Height = anim.Config.Width, anim.Config.Height
+
+	case "image/jpeg":
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		// the orientation goes away with the EXIF data, so apply it
+		first = orient(decoded, exifOrientation(data))
+		if err := jpeg.Encode(&buf, first, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+		img.ThumbnailContentType = "image/jpeg"
+
+	case "image/png":
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		if err := png.Encode(&buf, decoded); err != nil {
+			return nil, err
+		}
+		first = decoded
+	}
+
+	img.Data = buf.Bytes()
+	if img.Width == 0 {
+		img.Width, img.Height = first.Bounds().Dx(), first.Bounds().Dy()
+	}
+
+	thumbnail := Thumbnail(first, thumbnailSize)
+
+	buf = bytes.Buffer{}
+	if img.ThumbnailContentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, thumbnail)
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Thumbnail = buf.Bytes()
+
+	return img, nil
+}
+
+// Thumbnail scales the image down to fit in a size square, averaging the
+// pixels each thumbnail pixel covers. Smaller images are left as they are.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return rgba
+	}
+
+	tw, th := size, size
+	if w > h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, max((ty+1)*h/th, ty*h/th+1)
+
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, max((tx+1)*w/tw, tx*w/tw+1)
+
+			// premultiplied, so averaging the channels is right
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := rgba.Pix[y*rgba.Stride+x0*4 : y*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			o := dst.PixOffset(tx, ty)
+			for c := range sum {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,192 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	return img
+}
+
+// withExif inserts an APP1 segment carrying the orientation right after
+// the JPEG's SOI marker.
+func withExif(jpg []byte, orientation uint16) []byte {
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+
+	return append(out, jpg[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+
+	t.Run("should strip EXIF data and apply its orientation", func(t *testing.T) {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, testImage(300, 200), nil)
+
+		data := withExif(buf.Bytes(), 6)
+		if exifOrientation(data) != 6 {
+			t.Fatal("the test image should carry an orientation")
+		}
+
+		img, err := Process(data, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/jpeg" || img.Width != 200 || img.Height != 300 {
+			t.Errorf("got %s %dx%d, want image/jpeg 200x300", img.ContentType, img.Width, img.Height)
+		}
+
+		if bytes.Contains(img.Data, []byte("Exif")) {
+			t.Error("the EXIF data should be stripped")
+		}
+
+		thumbnail, err := jpeg.DecodeConfig(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thumbnail.Width != 66 || thumbnail.Height != 100 {
+			t.Errorf("got a %dx%d thumbnail, want 66x100", thumbnail.Width, thumbnail.Height)
+		}
+	})
+
+	t.Run("should sniff the type and keep small images as they are", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, testImage(40, 20))
+
+		img, err := Process(buf.Bytes(), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img.ContentType != "image/png" || img.ThumbnailContentType != "image/png" {
+			t.Errorf("got %s with a %s thumbnail", img.ContentType, img.ThumbnailContentType)
+		}
+
+		thumbnail, err := png.DecodeConfig(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thumbnail.Width != 40 || thumbnail.Height != 20 {
+			t.Errorf("got a %dx%d thumbnail, want 40x20", thumbnail.Width, thumbnail.Height)
+		}
+	})
+
+	t.Run("should reject other types", func(t *testing.T) {
+		_, err := Process([]byte("<html><script>alert(1)</script></html>"), 100)
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("got %v, want %v", err, ErrUnsupportedType)
+		}
+	})
+
+	t.Run("should reject images claiming huge dimensions", func(t *testing.T) {
+		var buf bytes.Buffer
+		gif.Encode(&buf, testImage(1, 1), nil)
+
+		data := buf.Bytes()
+		binary.LittleEndian.PutUint16(data[6:], 10000)
+		binary.LittleEndian.PutUint16(data[8:], 10000)
+
+		_, err := Process(data, 100)
+		if !errors.Is(err, ErrTooManyPixels) {
+			t.Errorf("got %v, want %v", err, ErrTooManyPixels)
+		}
+	})
+
+	t.Run("should reject animations adding up to too many pixels", func(t *testing.T) {
+		frame := image.NewPaletted(image.Rect(0, 0, 1000, 1000), color.Palette{color.Black, color.White})
+
+		anim := &gif.GIF{}
+		for i := 0; i < MaxPixels/(1000*1000)+1; i++ {
+			anim.Image = append(anim.Image, frame)
+			anim.Delay = append(anim.Delay, 0)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := Process(buf.Bytes(), 100)
+		if !errors.Is(err, ErrTooManyPixels) {
+			t.Errorf("got %v, want %v", err, ErrTooManyPixels)
+		}
+	})
+
+	t.Run("should reject animations with too many frames", func(t *testing.T) {
+		frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+
+		anim := &gif.GIF{}
+		for i := 0; i < MaxFrames+1; i++ {
+			anim.Image = append(anim.Image, frame)
+			anim.Delay = append(anim.Delay, 0)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := Process(buf.Bytes(), 100)
+		if !errors.Is(err, ErrTooManyFrames) {
+			t.Errorf("got %v, want %v", err, ErrTooManyFrames)
+		}
+	})
+
+	t.Run("should reject broken images", func(t *testing.T) {
+		var buf bytes.Buffer
+		png.Encode(&buf, testImage(10, 10))
+
+		_, err := Process(buf.Bytes()[:60], 100)
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("got %v, want %v", err, ErrInvalidImage)
+		}
+	})
+}
+
+func TestThumbnail(t *testing.T) {
+
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		src.Set(x, 0, color.RGBA{200, 0, 0, 255})
+		src.Set(x, 1, color.RGBA{0, 0, 100, 255})
+	}
+
+	thumbnail := Thumbnail(src, 2)
+
+	if b := thumbnail.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("got %v, want 2x1", b)
+	}
+
+	if got, want := thumbnail.RGBAAt(0, 0), (color.RGBA{100, 0, 50, 255}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
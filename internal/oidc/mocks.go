@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MockIssuer is a local OpenID provider for tests. Authorize plays the part
+// of the user consenting on the provider login page.
+type MockIssuer struct {
+	*httptest.Server
+	ClientID      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// KeyFetches counts the requests for the key set
+	KeyFetches atomic.Int64
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockAuthRequest
+}
+
+type mockAuthRequest struct {
+	nonce     string
+	challenge string
+}
+
+func NewMockIssuer(clientID string) (*MockIssuer, error) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MockIssuer{
+		ClientID:      clientID,
+		Subject:       "mock-user",
+		Email:         "gopher@example.com",
+		EmailVerified: true,
+		key:           key,
+		codes:         map[string]mockAuthRequest{},
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.KeyFetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"kid": "mock-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", m.token)
+
+	m.Server = httptest.NewServer(mux)
+
+	return m, nil
+}
+
+// Authorize returns the code the provider would redirect back with.
+func (m *MockIssuer) Authorize(authURL string) (string, error) {
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockAuthRequest{
+		nonce:     u.Query().Get("nonce"),
+		challenge: u.Query().Get("code_challenge"),
+	}
+	m.mu.Unlock()
+
+	return code, nil
+}
+
+// IDToken signs an ID token for the configured account.
+func (m *MockIssuer) IDToken(audience, nonce string) (string, error) {
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            m.Subject,
+		"aud":            audience,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          m.Email,
+		"email_verified": m.EmailVerified,
+	})
+	token.Header["kid"] = "mock-key"
+
+	return token.SignedString(m.key)
+}
+
+func (m *MockIssuer) token(w http.ResponseWriter, r *http.Request) {
+
+	m.mu.Lock()
+	req, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostFormValue("client_id") != m.ClientID || S256Challenge(r.PostFormValue("code_verifier")) != req.challenge {
+		http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := m.IDToken(m.ClientID, req.nonce)
+	if err != nil {
+		http.Error(w, `{"error": "server_error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
@@ -0,0 +1,338 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE against any provider publishing a discovery document.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// keyRefetchInterval bounds how often an unknown key id triggers a refetch
+// of the key set, so forged key ids cannot hammer the provider.
+const keyRefetchInterval = time.Minute
+
+type Provider struct {
+	Name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+	fetchMu       sync.Mutex
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims used to find or create the local account.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// NewProvider does not contact the issuer, its discovery document is
+// fetched on first use so that an unreachable provider does not prevent
+// the API from starting.
+func NewProvider(name, issuer, clientID, clientSecret, redirectURL string) *Provider {
+
+	return &Provider{
+		Name:         name,
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the provider URL the user is sent to for logging in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", "openid email profile")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", S256Challenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return md.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades the authorization code for an ID token and returns its
+// verified claims. The nonce is left for the caller to check.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Claims, error) {
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("client_secret", p.clientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res struct {
+		IDToken string `json:"id_token"`
+	}
+
+	err = p.do(req, &res)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+
+	if res.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing from the token response", ErrInvalidIDToken)
+	}
+
+	return p.Verify(ctx, res.IDToken)
+}
+
+// Verify checks the signature, issuer, audience and expiry of an ID token.
+func (p *Provider) Verify(ctx context.Context, idToken string) (*Claims, error) {
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+
+	_, err = jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &metadata{}
+
+	err = p.do(req, md)
+	if err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", p.issuer, err)
+	}
+
+	// the issuer in the document must be the one we were configured with
+	if strings.TrimRight(md.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", md.Issuer, p.issuer)
+	}
+
+	p.metadata = md
+
+	return md, nil
+}
+
+// key returns the verification key with the given id, refetching the key
+// set when the id is unknown since providers rotate their keys. Refetches
+// happen one at a time and at most once per keyRefetchInterval.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+
+	key, fetchedAt, ok := p.cachedKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(fetchedAt) < keyRefetchInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	p.fetchMu.Lock()
+	defer p.fetchMu.Unlock()
+
+	// another request may have refetched the set while this one waited
+	key, fetchedAt, ok = p.cachedKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(fetchedAt) < keyRefetchInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *Provider) cachedKey(kid string) (any, time.Time, bool) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+
+	return key, p.keysFetchedAt, ok
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]any, error) {
+
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err = p.do(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetching keys: %w", err)
+	}
+
+	keys := map[string]any{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		public, err := k.publicKey()
+		if err != nil {
+			// keys of unsupported types are ignored
+			continue
+		}
+
+		keys[k.Kid] = public
+	}
+
+	return keys, nil
+}
+
+func (p *Provider) do(req *http.Request, v any) error {
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// RandomString returns a url-safe random string, used for the state, the
+// nonce and the PKCE code verifier.
+func RandomString() (string, error) {
+
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256Challenge derives the PKCE code challenge of a verifier (RFC 7636).
+func S256Challenge(verifier string) string {
+
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestProvider(t *testing.T) {
+	issuer, err := NewMockIssuer("client-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer issuer.Close()
+
+	ctx := context.Background()
+	provider := NewProvider("mock", issuer.URL, "client-id", "client-secret", "http://localhost/callback")
+
+	authURL, err := provider.AuthCodeURL(ctx, "a-state", "a-nonce", "a-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should build the authorization URL", func(t *testing.T) {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Query().Get("code_challenge") != S256Challenge("a-verifier") || u.Query().Get("state") != "a-state" {
+			t.Errorf("unexpected authorization URL %s", authURL)
+		}
+	})
+
+	t.Run("should exchange a code for verified claims", func(t *testing.T) {
+		code, err := issuer.Authorize(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := provider.Exchange(ctx, code, "a-verifier")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claims.Subject != issuer.Subject || claims.Nonce != "a-nonce" || !claims.EmailVerified {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("should refuse a wrong code verifier", func(t *testing.T) {
+		code, err := issuer.Authorize(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.Exchange(ctx, code, "another-verifier")
+		if err == nil {
+			t.Fatal("expected the exchange to fail")
+		}
+	})
+
+	t.Run("should refuse a token issued to another client", func(t *testing.T) {
+		idToken, err := issuer.IDToken("another-client", "a-nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.Verify(ctx, idToken)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("should not refetch the keys for every unknown key id", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": issuer.URL,
+			"sub": issuer.Subject,
+			"aud": "client-id",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "unknown-key"
+
+		idToken, err := token.SignedString(issuer.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fetches := issuer.KeyFetches.Load()
+
+		for i := 0; i < 3; i++ {
+			_, err = provider.Verify(ctx, idToken)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		}
+
+		if issuer.KeyFetches.Load() != fetches {
+			t.Errorf("expected no refetch within the interval, got %d", issuer.KeyFetches.Load()-fetches)
+		}
+	})
+}
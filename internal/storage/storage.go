@@ -0,0 +1,22 @@
+// Package storage keeps blobs, such as uploaded media, on the local
+// filesystem or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Blobs stores opaque blobs under slash separated keys, e.g.
+// "posts/12/3f2a.jpg".
+type Blobs interface {
+	// Put stores the blob, replacing the one under the same key if any.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete succeeds when there is no blob under the key.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the blob from.
+	URL(key string) string
+}
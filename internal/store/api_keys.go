@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every personal access token, so they can be told
+// apart from JWTs and spotted by secret scanners.
+const APIKeyPrefix = "gsk_"
+
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopeFeedRead   = "feed:read"
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+)
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Token      string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expiry"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type APIKeyStore struct {
+	db *sql.DB
+}
+
+// Create stores an API key. The token is expected to be already hashed.
+func (s *APIKeyStore) Create(ctx context.Context, key *APIKey) error {
+
+	query := `
+		INSERT INTO api_keys
+			(user_id, name, prefix, token, scopes, expiry)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Token, pq.Array(key.Scopes), key.Expiry}
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *APIKeyStore) GetByUserID(ctx context.Context, userID int64) ([]APIKey, error) {
+
+	query := `
+		SELECT
+			id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []APIKey{}
+
+	for rows.Next() {
+
+		var key APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Scopes),
+			&key.Expiry,
+			&key.LastUsedAt,
+			&key.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *APIKeyStore) GetByID(ctx context.Context, id, userID int64) (*APIKey, error) {
+
+	query := `
+		SELECT
+			id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanOne(s.db.QueryRowContext(ctx, query, id, userID))
+}
+
+// GetByToken finds the unexpired key matching the given plain token.
+func (s *APIKeyStore) GetByToken(ctx context.Context, token string) (*APIKey, error) {
+
+	query := `
+		SELECT
+			id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE token = $1 AND (expiry IS NULL OR expiry > $2)`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.scanOne(s.db.QueryRowContext(ctx, query, hashToken, time.Now()))
+}
+
+// Touch records the key usage, at most once a minute to spare writes.
+func (s *APIKeyStore) Touch(ctx context.Context, id int64) error {
+
+	query := `
+		UPDATE api_keys
+			SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+func (s *APIKeyStore) Delete(ctx context.Context, id, userID int64) error {
+
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *APIKeyStore) scanOne(row *sql.Row) (*APIKey, error) {
+
+	key := &APIKey{}
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&key.Expiry,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrTooManyAttachments = errors.New("too many attachments")
+
+// Attachment is an image attached to a post. Its blobs are stored under
+// Key and ThumbnailKey, the URLs being filled in by the API.
+type Attachment struct {
+	ID           int64  `json:"id"`
+	PostID       int64  `json:"post_id"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// Attachments scans the JSON array built by attachmentsQuery.
+type Attachments []Attachment
+
+func (a *Attachments) Scan(src any) error {
+
+	var data []byte
+
+	switch src := src.(type) {
+	case nil:
+		*a = Attachments{}
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Attachments", src)
+	}
+
+	// the keys are left out of the API's JSON but not of the query's
+	var rows []struct {
+		Attachment
+		Key          string `json:"key"`
+		ThumbnailKey string `json:"thumbnail_key"`
+	}
+
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+
+	*a = make(Attachments, len(rows))
+	for i, row := range rows {
+		(*a)[i] = row.Attachment
+		(*a)[i].Key = row.Key
+		(*a)[i].ThumbnailKey = row.ThumbnailKey
+	}
+
+	return nil
+}
+
+// attachmentsQuery returns a subquery building the attachments of the post
+// identified by the given SQL expression, in the order they were added.
+func attachmentsQuery(id string) string {
+
+	return fmt.Sprintf(`(
+		SELECT COALESCE(json_agg(a ORDER BY a.id), '[]')
+		FROM (
+			SELECT id, post_id, key, thumbnail_key, content_type, size, width, height, created_at
+			FROM post_attachments WHERE post_id = %s
+		) a
+	)`, id)
+}
+
+type AttachmentStore struct {
+	db *sql.DB
+}
+
+// Create adds the attachment to its post, unless the post already has max
+// attachments.
+func (s *AttachmentStore) Create(ctx context.Context, attachment *Attachment, max int) error {
+
+	query := `
+		INSERT INTO post_attachments
+			(post_id, key, thumbnail_key, content_type, size, width, height)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE (SELECT COUNT(*) FROM post_attachments WHERE post_id = $1) < $8
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		// serializes the uploads to the post
+		var id int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM posts WHERE id = $1 FOR NO KEY UPDATE`, attachment.PostID).Scan(&id)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			attachment.PostID,
+			attachment.Key,
+			attachment.ThumbnailKey,
+			attachment.ContentType,
+			attachment.Size,
+			attachment.Width,
+			attachment.Height,
+			max,
+		).Scan(&attachment.ID, &attachment.CreatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrTooManyAttachments
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Detach removes the attachment from the post, leaving its blobs to the
+// sweeper.
+func (s *AttachmentStore) Detach(ctx context.Context, postID, id int64) error {
+
+	query := `UPDATE post_attachments SET post_id = NULL WHERE id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDetached lists up to limit attachments detached from their post,
+// deleted posts included.
+func (s *AttachmentStore) GetDetached(ctx context.Context, limit int) ([]Attachment, error) {
+
+	query := `
+		SELECT id, key, thumbnail_key
+		FROM post_attachments
+		WHERE post_id IS NULL
+		ORDER BY id
+		LIMIT $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+
+	for rows.Next() {
+		var attachment Attachment
+
+		if err := rows.Scan(&attachment.ID, &attachment.Key, &attachment.ThumbnailKey); err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// Delete removes a detached attachment once its blobs are gone.
+func (s *AttachmentStore) Delete(ctx context.Context, id int64) error {
+
+	query := `DELETE FROM post_attachments WHERE id = $1 AND post_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+
+	return err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	AuditActionImpersonate = "impersonation.start"
+	// a write made with an impersonation token
+	AuditActionImpersonatedRequest = "impersonation.request"
+)
+
+// AuditLog records an action taken by the actor on behalf of the user.
+type AuditLog struct {
+	ID        int64  `json:"id"`
+	ActorID   int64  `json:"actor_id"`
+	UserID    int64  `json:"user_id"`
+	Action    string `json:"action"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	IPAddress string `json:"ip_address"`
+	CreatedAt string `json:"created_at"`
+}
+
+type AuditLogStore struct {
+	db *sql.DB
+}
+
+func (s *AuditLogStore) Create(ctx context.Context, log *AuditLog) error {
+
+	query := `
+		INSERT INTO audit_logs
+			(actor_id, user_id, action, method, path, status, ip_address)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{log.ActorID, log.UserID, log.Action, log.Method, log.Path, log.Status, log.IPAddress}
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&log.ID, &log.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateStatus records the response status of a request logged before it
+// was served.
+func (s *AuditLogStore) UpdateStatus(ctx context.Context, id int64, status int) error {
+
+	query := `UPDATE audit_logs SET status = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, status, id)
+
+	return err
+}
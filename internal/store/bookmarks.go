@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Create bookmarks a post, bookmarking it again is a no-op.
+func (s *BookmarkStore) Create(ctx context.Context, userID, postID int64) error {
+
+	query := `
+		INSERT INTO bookmarks
+			(user_id, post_id)
+		VALUES
+			($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+
+	return err
+}
+
+func (s *BookmarkStore) Delete(ctx context.Context, userID, postID int64) error {
+
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) Exists(ctx context.Context, userID, postID int64) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = $1 AND post_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&exists)
+
+	return exists, err
+}
+
+// GetByUserID lists the bookmarked posts of the user in the order they were
+// saved, filtered like the feed.
+func (s *BookmarkStore) GetByUserID(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+
+	source := `
+		SELECT post_id, NULL::bigint AS reposted_by, created_at AS activity_at
+		FROM bookmarks
+		WHERE user_id = $1`
+
+	return queryPostsWithMetadata(ctx, s.db, userID, fq, source, publishedOnly)
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/high-la/gopher-social/internal/store"
 	"github.com/stretchr/testify/mock"
@@ -10,7 +11,8 @@ import (
 func NewMockStore() Storage {
 
 	return Storage{
-		Users: &MockUserStore{},
+		Users:         &MockUserStore{},
+		RevokedTokens: &MockRevokedTokenStore{},
 	}
 }
 
@@ -21,10 +23,33 @@ type MockUserStore struct {
 func (m *MockUserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
 
 	args := m.Called(userID)
-	return nil, args.Error(2)
+	return nil, args.Error(1)
 }
 
 func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
+
+func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockRevokedTokenStore struct{}
+
+func (m *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	return nil
+}
+
+func (m *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockRevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiry time.Time) error {
+	return nil
+}
+
+func (m *MockRevokedTokenStore) UserRevokedAt(ctx context.Context, userID int64) (time.Time, error) {
+	return time.Time{}, nil
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RevokedTokenStore struct {
+	rdb *redis.Client
+}
+
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+
+	if s.rdb == nil {
+		return nil
+	}
+
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	return s.rdb.SetEx(ctx, cacheKey, 1, ttl).Err()
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+
+	if s.rdb == nil {
+		return false, nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	count, err := s.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (s *RevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiry time.Time) error {
+
+	if s.rdb == nil {
+		return nil
+	}
+
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-user-%v", userID)
+
+	return s.rdb.SetEx(ctx, cacheKey, time.Now().Unix(), ttl).Err()
+}
+
+func (s *RevokedTokenStore) UserRevokedAt(ctx context.Context, userID int64) (time.Time, error) {
+
+	if s.rdb == nil {
+		return time.Time{}, nil
+	}
+
+	cacheKey := fmt.Sprintf("revoked-user-%v", userID)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	unix, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(unix, 0), nil
+}
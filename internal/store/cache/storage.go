@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/high-la/gopher-social/internal/store"
 	"github.com/redis/go-redis/v9"
@@ -11,12 +12,20 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	RevokedTokens interface {
+		Revoke(context.Context, string, time.Time) error
+		IsRevoked(context.Context, string) (bool, error)
+		RevokeUser(context.Context, int64, time.Time) error
+		UserRevokedAt(context.Context, int64) (time.Time, error)
 	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:         &UserStore{rdb: rdb},
+		RevokedTokens: &RevokedTokenStore{rdb: rdb},
 	}
 }
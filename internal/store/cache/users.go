@@ -56,3 +56,14 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	return s.rdb.SetEx(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+
+	if s.rdb == nil {
+		return nil
+	}
+
+	cacheKey := fmt.Sprintf("user-%v", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
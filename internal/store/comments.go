@@ -3,38 +3,126 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
+)
+
+// DeletedCommentContent stands in for a deleted comment kept for its replies.
+const DeletedCommentContent = "[deleted]"
+
+// Deleted comments and the comments of deleted users are only listed, as
+// placeholders, when they have replies. They are kept until purged so they
+// can be restored.
+const (
+	commentDeleted = `(c.deleted_at IS NOT NULL OR u.deleted_at IS NOT NULL)`
+	commentListed  = `(NOT ` + commentDeleted + ` OR EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id))`
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID       int64  `json:"id"`
+	PostID   int64  `json:"post_id"`
+	ParentID *int64 `json:"parent_id"`
+	// 0 for comments on the post, 1 for their replies and so on
+	Depth      int            `json:"depth"`
+	UserID     int64          `json:"user_id"`
+	Content    string         `json:"content"`
+	ReplyCount int            `json:"reply_count"`
+	Reactions  ReactionCounts `json:"reactions"`
+	Deleted    bool           `json:"deleted"`
+	// set while the comment is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+	User      User       `json:"user"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+var commentColumns = `
+	c.id, c.post_id, c.parent_id, c.depth, c.user_id, c.content, ` + commentDeleted + `,
+	(
+		SELECT COUNT(*) FROM comments r
+			JOIN users ru ON ru.id = r.user_id
+		WHERE r.parent_id = c.id AND (
+			(r.deleted_at IS NULL AND ru.deleted_at IS NULL) OR EXISTS (SELECT 1 FROM comments rr WHERE rr.parent_id = r.id)
+		)
+	) AS reply_count,
+	` + reactionCountsQuery(commentReactions, "c.id") + ` AS reactions,
+	c.created_at, c.updated_at, c.deleted_at, u.username, u.id`
+
+func scanComment(row interface{ Scan(...any) error }, comment *Comment) error {
+
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.ParentID,
+		&comment.Depth,
+		&comment.UserID,
+		&comment.Content,
+		&comment.Deleted,
+		&comment.ReplyCount,
+		&comment.Reactions,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.DeletedAt,
+		&comment.User.Username,
+		&comment.User.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByPostID lists the comments made on the post itself, oldest first, one
+// page at a time. The returned cursor is empty on the last page.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID int64, cq CursorQuery) ([]Comment, string, error) {
+
+	return s.list(ctx, `c.post_id = $1 AND c.parent_id IS NULL`, postID, cq)
+}
+
+// GetReplies lists the direct replies to a comment, oldest first, one page
+// at a time. The returned cursor is empty on the last page.
+func (s *CommentStore) GetReplies(ctx context.Context, parentID int64, cq CursorQuery) ([]Comment, string, error) {
+
+	return s.list(ctx, `c.parent_id = $1`, parentID, cq)
+}
+
+// hideDeleted turns a deleted comment into its placeholder, which does not
+// tell who wrote it.
+func (c *Comment) hideDeleted() {
+
+	if !c.Deleted {
+		return
+	}
+
+	c.UserID = 0
+	c.User = User{}
+	c.Content = DeletedCommentContent
+	c.DeletedAt = nil
+}
+
+func (s *CommentStore) list(ctx context.Context, where string, arg int64, cq CursorQuery) ([]Comment, string, error) {
 
 	query := `
-		SELECT 
-			c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id
+		SELECT ` + commentColumns + `
 		FROM comments c
 		JOIN users u
 			ON u.id = c.user_id
-		WHERE c.post_id = $1
-		ORDER BY c.id DESC`
+		WHERE ` + where + ` AND ` + commentListed + ` AND c.id > $2
+		ORDER BY c.id ASC
+		LIMIT $3`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	// one more row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, arg, cq.afterID(), cq.Limit+1)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	defer rows.Close()
@@ -45,42 +133,211 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 
 		var comment Comment
 
-		comment.User = User{}
-
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.User.Username,
-			&comment.User.ID,
-		)
+		err := scanComment(rows, &comment)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		comment.hideDeleted()
+
 		comments = append(comments, comment)
 	}
 
-	return comments, nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(comments) <= cq.Limit {
+		return comments, "", nil
+	}
+
+	comments = comments[:cq.Limit]
+
+	return comments, encodeCursor(comments[len(comments)-1].ID), nil
 }
 
+// GetByID returns the comment unless it was deleted, by itself or along
+// with its author.
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+
+	return s.get(ctx, `c.id = $1 AND NOT `+commentDeleted, id)
+}
+
+// GetListedByID returns the comment as it is listed, a deleted one with
+// replies being returned as its placeholder.
+func (s *CommentStore) GetListedByID(ctx context.Context, id int64) (*Comment, error) {
+
+	comment, err := s.get(ctx, `c.id = $1 AND `+commentListed, id)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.hideDeleted()
+
+	return comment, nil
+}
+
+// GetDeletedByID returns the comment only if it was deleted by itself, so
+// it can be restored.
+func (s *CommentStore) GetDeletedByID(ctx context.Context, id int64) (*Comment, error) {
+
+	return s.get(ctx, `c.id = $1 AND c.deleted_at IS NOT NULL AND u.deleted_at IS NULL`, id)
+}
+
+func (s *CommentStore) get(ctx context.Context, where string, id int64) (*Comment, error) {
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u
+			ON u.id = c.user_id
+		WHERE ` + where
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var comment Comment
+
+	err := scanComment(s.db.QueryRowContext(ctx, query, id), &comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// Create stores a comment, or a reply when its ParentID is set. The depth
+// is expected to be already computed from the parent.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 
 	query := `
-		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at`
+		INSERT INTO comments (post_id, parent_id, depth, user_id, content)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content).
-		Scan(&comment.ID, &comment.CreatedAt)
+	args := []any{comment.PostID, comment.ParentID, comment.Depth, comment.UserID, comment.Content}
+
+	err := s.db.QueryRowContext(ctx, query, args...).
+		Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+
+	query := `
+		UPDATE comments
+		SET content = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete moves the comment to the trash. It is listed as a placeholder
+// while it has replies.
+func (s *CommentStore) Delete(ctx context.Context, id int64) error {
+
+	return setDeletedAt(ctx, s.db, "comments", id, true)
+}
+
+// Restore takes the comment back out of the trash.
+func (s *CommentStore) Restore(ctx context.Context, id int64) error {
+
+	return setDeletedAt(ctx, s.db, "comments", id, false)
+}
+
+// GetDeletedByUserID lists the comments of the user in the trash, most
+// recently deleted first.
+func (s *CommentStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]Comment, error) {
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u
+			ON u.id = c.user_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NOT NULL
+		ORDER BY c.deleted_at DESC, c.id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []Comment{}
+
+	for rows.Next() {
+
+		var comment Comment
+
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// PurgeDeleted removes for good the comments deleted before the given time
+// and left without replies, placeholders included once their last reply is
+// gone, and returns how many there were.
+func (s *CommentStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+
+	query := `
+		DELETE FROM comments c
+		WHERE c.deleted_at < $1 AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var purged int64
+
+	for {
+		res, err := s.db.ExecContext(ctx, query, deletedBefore)
+		if err != nil {
+			return purged, err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+
+		if rows == 0 {
+			return purged, nil
+		}
+
+		purged += rows
+	}
+}
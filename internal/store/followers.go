@@ -50,3 +50,17 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64)
 
 	return err
 }
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+
+	return following, err
+}
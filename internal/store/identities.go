@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Identity links a user to an account of an external OpenID provider.
+type Identity struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"-"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+// OIDCState is a pending authorization request. UserID is set when an
+// authenticated user links a provider rather than logging in. Binding is the
+// hash of the cookie given to the browser that started the flow.
+type OIDCState struct {
+	State        string
+	Binding      string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       *int64
+	Expiry       time.Time
+}
+
+type IdentityStore struct {
+	db *sql.DB
+}
+
+// GetUser returns the active user linked to the provider account.
+func (s *IdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+
+	query := `
+		SELECT
+			u.id, u.username, u.email, u.created_at, r.*
+		FROM users u
+		JOIN roles r ON u.role_id = r.id
+		JOIN user_identities ui ON u.id = ui.user_id
+		WHERE ui.provider = $1 AND ui.subject = $2 AND u.is_active = true AND u.deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := s.db.QueryRowContext(ctx, query, provider, subject).
+		Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.CreatedAt,
+			//
+			&user.Role.ID,
+			&user.Role.Name,
+			&user.Role.Level,
+			&user.Role.Description,
+		)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *IdentityStore) GetByUserID(ctx context.Context, userID int64) ([]Identity, error) {
+
+	query := `
+		SELECT
+			id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY provider`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	identities := []Identity{}
+
+	for rows.Next() {
+
+		var identity Identity
+
+		err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Link returns ErrConflict when the provider account is already linked, or
+// when the user already has an account of that provider.
+func (s *IdentityStore) Link(ctx context.Context, identity *Identity) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.link(ctx, tx, identity)
+	})
+}
+
+func (s *IdentityStore) Unlink(ctx context.Context, userID int64, provider string) error {
+
+	query := `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateUser registers a user from a provider account. Users created
+// inactive still have to be invited to activate their account.
+func (s *IdentityStore) CreateUser(ctx context.Context, user *User, identity *Identity) error {
+
+	users := &UserStore{s.db}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		// 01. create the user
+		err := users.Create(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		// 02. trust the provider about the email
+		if user.IsActive {
+			err = users.update(ctx, tx, user)
+			if err != nil {
+				return err
+			}
+		}
+
+		// 03. link the provider account
+		identity.UserID = user.ID
+
+		return s.link(ctx, tx, identity)
+	})
+}
+
+// CreateState stores a pending authorization request. The state is expected
+// to be already hashed.
+func (s *IdentityStore) CreateState(ctx context.Context, state *OIDCState) error {
+
+	query := `
+		INSERT INTO oidc_states
+			(state, binding, provider, nonce, code_verifier, user_id, expiry)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, state.State, state.Binding, state.Provider, state.Nonce, state.CodeVerifier, state.UserID, state.Expiry)
+
+	return err
+}
+
+// ConsumeState spends the unexpired authorization request of the plain state.
+func (s *IdentityStore) ConsumeState(ctx context.Context, state string) (*OIDCState, error) {
+
+	query := `
+		DELETE FROM oidc_states
+		WHERE state = $1
+		RETURNING binding, provider, nonce, code_verifier, user_id, expiry`
+
+	hash := sha256.Sum256([]byte(state))
+	hashState := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	pending := &OIDCState{State: hashState}
+
+	err := s.db.QueryRowContext(ctx, query, hashState).
+		Scan(&pending.Binding, &pending.Provider, &pending.Nonce, &pending.CodeVerifier, &pending.UserID, &pending.Expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if pending.Expiry.Before(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	return pending, nil
+}
+
+// DeleteExpiredStates removes the authorization requests never completed.
+func (s *IdentityStore) DeleteExpiredStates(ctx context.Context) (int64, error) {
+
+	query := `DELETE FROM oidc_states WHERE expiry < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *IdentityStore) link(ctx context.Context, tx *sql.Tx, identity *Identity) error {
+
+	query := `
+		INSERT INTO user_identities
+			(user_id, provider, subject, email)
+		VALUES
+			($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttempt struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email"`
+	IPAddress string `json:"ip_address"`
+	Succeeded bool   `json:"succeeded"`
+	CreatedAt string `json:"created_at"`
+}
+
+type LoginAttemptStore struct {
+	db *sql.DB
+}
+
+func (s *LoginAttemptStore) Create(ctx context.Context, attempt *LoginAttempt) error {
+
+	query := `
+		INSERT INTO login_attempts
+			(email, ip_address, succeeded)
+		VALUES
+			($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, attempt.Email, attempt.IPAddress, attempt.Succeeded).
+		Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CountFailuresByEmail counts the failed attempts for an account since the
+// given time, ignoring the ones that happened before its last successful login.
+func (s *LoginAttemptStore) CountFailuresByEmail(ctx context.Context, email string, since time.Time) (int, error) {
+
+	query := `
+		SELECT COUNT(*)
+		FROM login_attempts
+		WHERE
+			email = $1 AND succeeded = false AND created_at > $2 AND
+			created_at > COALESCE(
+				(SELECT MAX(created_at) FROM login_attempts WHERE email = $1 AND succeeded = true),
+				'-infinity'
+			)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+
+	err := s.db.QueryRowContext(ctx, query, email, since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *LoginAttemptStore) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+
+	query := `
+		SELECT COUNT(*)
+		FROM login_attempts
+		WHERE ip_address = $1 AND succeeded = false AND created_at > $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+
+	err := s.db.QueryRowContext(ctx, query, ip, since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
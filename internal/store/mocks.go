@@ -2,28 +2,99 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// MockPassword is the password of every user returned by MockUserStore.
+const MockPassword = "password"
+
+// MockAdminID is the id of the only admin returned by MockUserStore.
+const MockAdminID int64 = 1000
+
+// MockUnknownEmail is the only email MockUserStore has no user for.
+const MockUnknownEmail = "unknown@example.com"
+
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:         &MockPostStore{},
+		Followers:     &MockFollowerStore{},
+		Comments:      &MockCommentStore{},
+		Users:         &MockUserStore{},
+		Roles:         &MockRoleStore{},
+		LoginAttempts: &MockLoginAttemptStore{},
+		RefreshTokens: &MockRefreshTokenStore{},
+		RevokedTokens: &MockRevokedTokenStore{},
+		APIKeys:       &MockAPIKeyStore{},
+		TwoFactor:     &MockTwoFactorStore{},
+		Identities:    &MockIdentityStore{},
+		Sessions:      &MockSessionStore{revoked: map[string]bool{}},
+		AuditLogs:     &MockAuditLogStore{},
+		Reactions:     &MockReactionStore{},
+		Bookmarks:     &MockBookmarkStore{},
+		Reposts:       &MockRepostStore{},
+		PostRevisions: &MockPostRevisionStore{},
+		Attachments:   &MockAttachmentStore{},
+		Polls:         &MockPollStore{},
+		Pins:          &MockPinStore{},
 	}
 }
 
-type MockUserStore struct{}
+// MockUserStore keeps the password reset tokens in memory, everything else
+// is answered with a canned user.
+type MockUserStore struct {
+	passwordResets []mockPasswordReset
+	// MagicLinks counts the login links created
+	MagicLinks int
+}
+
+type mockPasswordReset struct {
+	token  string
+	userID int64
+	expiry time.Time
+}
 
 func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, u *User) error {
 	return nil
 }
 
 func (m *MockUserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
-	return &User{ID: userID}, nil
+	if userID == MockAdminID {
+		return &User{ID: userID, Role: Role{Name: "admin", Level: "3"}}, nil
+	}
+	return &User{ID: userID, Role: Role{Name: "user", Level: "1"}}, nil
 }
 
-func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
-	return &User{}, nil
+func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	if email == MockUnknownEmail {
+		return nil, ErrNotFound
+	}
+
+	user := &User{ID: 1, Email: email, Role: Role{Name: "user", Level: "1"}}
+	err := user.Password.Set(MockPassword)
+
+	return user, err
+}
+
+func (m *MockUserStore) GetInactiveByEmail(ctx context.Context, email string) (*User, error) {
+	return &User{ID: 1, Email: email}, nil
+}
+
+func (m *MockUserStore) RotateInvitation(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) DeleteUnactivated(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	return 0, nil
 }
 
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error {
@@ -37,3 +108,712 @@ func (m *MockUserStore) Activate(ctx context.Context, t string) error {
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 	return nil
 }
+
+func (m *MockUserStore) Restore(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockUserStore) GetDeletedByID(ctx context.Context, userID int64) (*User, error) {
+	return m.GetByID(ctx, userID)
+}
+
+func (m *MockUserStore) Purge(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockUserStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	m.passwordResets = append(m.passwordResets, mockPasswordReset{token: token, userID: userID, expiry: time.Now().Add(exp)})
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token, password string) (*User, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	i := slices.IndexFunc(m.passwordResets, func(pr mockPasswordReset) bool {
+		return pr.token == hashToken && pr.expiry.After(time.Now())
+	})
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	userID := m.passwordResets[i].userID
+	m.passwordResets = slices.DeleteFunc(m.passwordResets, func(pr mockPasswordReset) bool {
+		return pr.userID == userID
+	})
+
+	return &User{ID: userID}, nil
+}
+
+func (m *MockUserStore) CreateMagicLink(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	m.MagicLinks++
+	return nil
+}
+
+func (m *MockUserStore) ConsumeMagicLink(ctx context.Context, token string) (*User, error) {
+	return &User{ID: 1, Email: "gopher@example.com", Role: Role{Name: "user", Level: "1"}}, nil
+}
+
+// MockRoleStore knows the roles seeded by the migrations.
+type MockRoleStore struct{}
+
+func (m *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	levels := map[string]string{"user": "1", "moderator": "2", "admin": "3"}
+
+	level, ok := levels[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Role{Name: name, Level: level}, nil
+}
+
+type MockLoginAttemptStore struct {
+	failures int
+}
+
+func (m *MockLoginAttemptStore) Create(ctx context.Context, attempt *LoginAttempt) error {
+	if !attempt.Succeeded {
+		m.failures++
+	}
+	return nil
+}
+
+func (m *MockLoginAttemptStore) CountFailuresByEmail(ctx context.Context, email string, since time.Time) (int, error) {
+	return m.failures, nil
+}
+
+func (m *MockLoginAttemptStore) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, error) {
+	return m.failures, nil
+}
+
+type MockRefreshTokenStore struct{}
+
+func (m *MockRefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return nil
+}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+	next.UserID = 1
+	return nil
+}
+
+func (m *MockRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return nil
+}
+
+func (m *MockRefreshTokenStore) RevokeFamilyByToken(ctx context.Context, token string, userID int64) error {
+	return nil
+}
+
+func (m *MockRefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return nil
+}
+
+type MockRevokedTokenStore struct{}
+
+func (m *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+	return nil
+}
+
+func (m *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockRevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiry time.Time) error {
+	return nil
+}
+
+func (m *MockRevokedTokenStore) UserRevokedAt(ctx context.Context, userID int64) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+// MockAPIKeyStore knows a single key, owned by user 1 and scoped to reading users.
+type MockAPIKeyStore struct{}
+
+func (m *MockAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	return nil
+}
+
+func (m *MockAPIKeyStore) GetByUserID(ctx context.Context, userID int64) ([]APIKey, error) {
+	return []APIKey{}, nil
+}
+
+func (m *MockAPIKeyStore) GetByID(ctx context.Context, id, userID int64) (*APIKey, error) {
+	return &APIKey{ID: id, UserID: userID}, nil
+}
+
+func (m *MockAPIKeyStore) GetByToken(ctx context.Context, token string) (*APIKey, error) {
+	return &APIKey{ID: 1, UserID: 1, Scopes: []string{ScopeUsersRead}}, nil
+}
+
+func (m *MockAPIKeyStore) Touch(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockAPIKeyStore) Delete(ctx context.Context, id, userID int64) error {
+	return nil
+}
+
+// MockTwoFactorStore behaves as if no user had enrolled, unless a TOTP is set.
+type MockTwoFactorStore struct {
+	TOTP *TOTP
+}
+
+func (m *MockTwoFactorStore) Get(ctx context.Context, userID int64) (*TOTP, error) {
+	if m.TOTP == nil {
+		return nil, ErrNotFound
+	}
+	return m.TOTP, nil
+}
+
+func (m *MockTwoFactorStore) Enroll(ctx context.Context, userID int64, secret string) error {
+	m.TOTP = &TOTP{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (m *MockTwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	if m.TOTP == nil {
+		return ErrNotFound
+	}
+	m.TOTP.Enabled = true
+	m.TOTP.LastUsedStep = step
+	return nil
+}
+
+func (m *MockTwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	m.TOTP = nil
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+	if m.TOTP == nil || step <= m.TOTP.LastUsedStep {
+		return ErrConflict
+	}
+	m.TOTP.LastUsedStep = step
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return ErrNotFound
+}
+
+// MockIdentityStore has no linked identities and accepts any pending state
+// stored in State.
+type MockIdentityStore struct {
+	State *OIDCState
+}
+
+func (m *MockIdentityStore) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockIdentityStore) GetByUserID(ctx context.Context, userID int64) ([]Identity, error) {
+	return []Identity{}, nil
+}
+
+func (m *MockIdentityStore) Link(ctx context.Context, identity *Identity) error {
+	return nil
+}
+
+func (m *MockIdentityStore) Unlink(ctx context.Context, userID int64, provider string) error {
+	return nil
+}
+
+func (m *MockIdentityStore) CreateUser(ctx context.Context, user *User, identity *Identity) error {
+	user.ID = 1
+	identity.UserID = user.ID
+	return nil
+}
+
+func (m *MockIdentityStore) CreateState(ctx context.Context, state *OIDCState) error {
+	m.State = state
+	return nil
+}
+
+func (m *MockIdentityStore) ConsumeState(ctx context.Context, state string) (*OIDCState, error) {
+	if m.State == nil {
+		return nil, ErrNotFound
+	}
+	pending := m.State
+	m.State = nil
+	return pending, nil
+}
+
+func (m *MockIdentityStore) DeleteExpiredStates(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// MockSessionStore knows every session, and remembers the revoked ones.
+type MockSessionStore struct {
+	revoked map[string]bool
+}
+
+func (m *MockSessionStore) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	token.UserID = session.UserID
+	token.FamilyID = session.ID
+	return nil
+}
+
+func (m *MockSessionStore) GetByUserID(ctx context.Context, userID int64, seenSince time.Time) ([]Session, error) {
+	return []Session{}, nil
+}
+
+func (m *MockSessionStore) Touch(ctx context.Context, id, ip string) (bool, error) {
+	return m.revoked[id], nil
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, id string, userID int64) error {
+	m.revoked[id] = true
+	return nil
+}
+
+func (m *MockSessionStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockSessionStore) DeleteInactive(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// MockAuditLogStore keeps the created logs for tests to inspect. Create
+// fails with Err when it is set.
+type MockAuditLogStore struct {
+	Logs []AuditLog
+	Err  error
+}
+
+func (m *MockAuditLogStore) Create(ctx context.Context, log *AuditLog) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	log.ID = int64(len(m.Logs) + 1)
+	m.Logs = append(m.Logs, *log)
+	return nil
+}
+
+func (m *MockAuditLogStore) UpdateStatus(ctx context.Context, id int64, status int) error {
+	for i := range m.Logs {
+		if m.Logs[i].ID == id {
+			m.Logs[i].Status = status
+		}
+	}
+	return nil
+}
+
+// MockPostStore knows every post but MockMissingPostID, all of them
+// written by user 1, public but MockPrivatePostID and published but
+// MockDraftPostID. Only MockPollPostID and MockClosedPollPostID have a poll.
+type MockPostStore struct {
+	// PublishRuns counts the calls to PublishScheduled
+	PublishRuns atomic.Int64
+
+	// Scheduled are the posts PublishScheduled publishes in due time
+	Scheduled []*Post
+	mu        sync.Mutex
+}
+
+const (
+	// MockMissingPostID is the id of the only post MockPostStore does not find.
+	MockMissingPostID int64 = 404
+	// MockPrivatePostID is the id of the only private post of MockPostStore.
+	MockPrivatePostID int64 = 403
+	// MockDraftPostID is the id of the only draft of MockPostStore.
+	MockDraftPostID int64 = 402
+	// MockPollPostID has an open single-choice poll with options 1 and 2.
+	MockPollPostID int64 = 405
+	// MockClosedPollPostID has a closed poll with options 1 and 2.
+	MockClosedPollPostID int64 = 406
+)
+
+func (m *MockPostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	switch id {
+	case MockMissingPostID:
+		return nil, ErrNotFound
+	case MockPrivatePostID:
+		return &Post{ID: id, UserID: 1, Visibility: VisibilityPrivate, Status: PostStatusPublished}, nil
+	case MockDraftPostID:
+		return &Post{ID: id, UserID: 1, Visibility: VisibilityPublic, Status: PostStatusDraft}, nil
+	}
+	post := &Post{ID: id, UserID: 1, Visibility: VisibilityPublic, Status: PostStatusPublished, Attachments: mockAttachments(id)}
+	post.Poll, _ = (&MockPollStore{}).Get(ctx, id, 0)
+	return post, nil
+}
+
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) Update(ctx context.Context, post *Post, editorID int64) error {
+	return nil
+}
+
+func (m *MockPostStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockPostStore) Restore(ctx context.Context, id int64) error {
+	return nil
+}
+
+// GetDeletedByID finds every post but MockMissingPostID in the trash.
+func (m *MockPostStore) GetDeletedByID(ctx context.Context, id int64) (*Post, error) {
+	if id == MockMissingPostID {
+		return nil, ErrNotFound
+	}
+
+	deletedAt := time.Now()
+
+	return &Post{ID: id, UserID: 1, DeletedAt: &deletedAt}, nil
+}
+
+func (m *MockPostStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]Post, error) {
+	return []Post{}, nil
+}
+
+func (m *MockPostStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockPostStore) GetMentions(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetByUserID(ctx context.Context, userID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetPinned(ctx context.Context, userID, viewerID int64, limit int) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) PublishScheduled(ctx context.Context) (int64, error) {
+	m.PublishRuns.Add(1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var published int64
+
+	for _, post := range m.Scheduled {
+		if post.Status != PostStatusScheduled || post.PublishAt.After(time.Now()) || post.DeletedAt != nil {
+			continue
+		}
+
+		post.Status = PostStatusPublished
+		post.Version++
+		published++
+	}
+
+	return published, nil
+}
+
+// ScheduledPost returns a copy of the scheduled post, safe to read while
+// PublishScheduled runs.
+func (m *MockPostStore) ScheduledPost(i int) Post {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return *m.Scheduled[i]
+}
+
+// MockCommentStore knows every comment, all of them written by user 1 on post 1.
+// MockDeletedCommentID is deleted and kept as a placeholder for its replies.
+type MockCommentStore struct{}
+
+const MockDeletedCommentID int64 = 99
+
+func (m *MockCommentStore) GetByPostID(ctx context.Context, postID int64, cq CursorQuery) ([]Comment, string, error) {
+	return []Comment{}, "", nil
+}
+
+func (m *MockCommentStore) GetReplies(ctx context.Context, parentID int64, cq CursorQuery) ([]Comment, string, error) {
+	return []Comment{}, "", nil
+}
+
+func (m *MockCommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	if id == MockDeletedCommentID {
+		return nil, ErrNotFound
+	}
+
+	return &Comment{ID: id, PostID: 1, UserID: 1}, nil
+}
+
+func (m *MockCommentStore) GetListedByID(ctx context.Context, id int64) (*Comment, error) {
+	if id == MockDeletedCommentID {
+		return &Comment{ID: id, PostID: 1, Content: DeletedCommentContent, Deleted: true}, nil
+	}
+
+	return m.GetByID(ctx, id)
+}
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Update(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockCommentStore) Restore(ctx context.Context, id int64) error {
+	return nil
+}
+
+// GetDeletedByID finds every comment in the trash too.
+func (m *MockCommentStore) GetDeletedByID(ctx context.Context, id int64) (*Comment, error) {
+	deletedAt := time.Now()
+
+	return &Comment{ID: id, PostID: 1, UserID: 1, Deleted: true, DeletedAt: &deletedAt}, nil
+}
+
+func (m *MockCommentStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]Comment, error) {
+	return []Comment{}, nil
+}
+
+func (m *MockCommentStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+// MockReactionStore knows no reaction: removing one always fails.
+type MockReactionStore struct{}
+
+func (m *MockReactionStore) SetOnPost(ctx context.Context, postID, userID int64, kind string) error {
+	return nil
+}
+
+func (m *MockReactionStore) RemoveFromPost(ctx context.Context, postID, userID int64, kind string) error {
+	return ErrNotFound
+}
+
+func (m *MockReactionStore) GetForPost(ctx context.Context, postID, viewerID int64) (*Reactions, error) {
+	return &Reactions{Counts: ReactionCounts{}}, nil
+}
+
+func (m *MockReactionStore) SetOnComment(ctx context.Context, commentID, userID int64, kind string) error {
+	return nil
+}
+
+func (m *MockReactionStore) RemoveFromComment(ctx context.Context, commentID, userID int64, kind string) error {
+	return ErrNotFound
+}
+
+func (m *MockReactionStore) GetForComment(ctx context.Context, commentID, viewerID int64) (*Reactions, error) {
+	return &Reactions{Counts: ReactionCounts{}}, nil
+}
+
+// MockBookmarkStore knows no bookmark: removing one always fails.
+type MockBookmarkStore struct{}
+
+func (m *MockBookmarkStore) Create(ctx context.Context, userID, postID int64) error {
+	return nil
+}
+
+func (m *MockBookmarkStore) Delete(ctx context.Context, userID, postID int64) error {
+	return ErrNotFound
+}
+
+func (m *MockBookmarkStore) Exists(ctx context.Context, userID, postID int64) (bool, error) {
+	return false, nil
+}
+
+func (m *MockBookmarkStore) GetByUserID(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+// MockRepostStore knows no repost: removing one always fails.
+type MockRepostStore struct{}
+
+func (m *MockRepostStore) Create(ctx context.Context, userID, postID int64) error {
+	return nil
+}
+
+func (m *MockRepostStore) Delete(ctx context.Context, userID, postID int64) error {
+	return ErrNotFound
+}
+
+// MockFollowerStore knows no follower.
+type MockFollowerStore struct{}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+
+func (m *MockFollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	return false, nil
+}
+
+// MockPostRevisionStore knows versions 0 and 1 of every post, edited by
+// user 1.
+type MockPostRevisionStore struct{}
+
+func (m *MockPostRevisionStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	revisions := []PostRevision{}
+
+	for version := 1; version >= 0; version-- {
+		revision, _ := m.Get(ctx, postID, version)
+		revisions = append(revisions, *revision)
+	}
+
+	return revisions, nil
+}
+
+func (m *MockPostRevisionStore) Get(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	contents := []string{"hello gophers", "hello rustaceans"}
+
+	if version < 0 || version >= len(contents) {
+		return nil, ErrNotFound
+	}
+
+	return &PostRevision{PostID: postID, Version: version, Title: "hello", Content: contents[version], Editor: &User{ID: 1}}, nil
+}
+
+// mockAttachments is the single attachment of posts returned by
+// MockPostStore, whose id is MockAttachmentID.
+func mockAttachments(postID int64) Attachments {
+	return Attachments{{
+		ID:           MockAttachmentID,
+		PostID:       postID,
+		Key:          "posts/1/mock.png",
+		ThumbnailKey: "posts/1/mock_thumb.png",
+		ContentType:  "image/png",
+	}}
+}
+
+const MockAttachmentID int64 = 1
+
+type MockAttachmentStore struct{}
+
+func (m *MockAttachmentStore) Create(ctx context.Context, attachment *Attachment, max int) error {
+	// the mock posts already have one attachment
+	if max <= 1 {
+		return ErrTooManyAttachments
+	}
+
+	attachment.ID = MockAttachmentID + 1
+	return nil
+}
+
+func (m *MockAttachmentStore) Detach(ctx context.Context, postID, id int64) error {
+	if id != MockAttachmentID {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MockAttachmentStore) GetDetached(ctx context.Context, limit int) ([]Attachment, error) {
+	return []Attachment{}, nil
+}
+
+func (m *MockAttachmentStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+type MockPollStore struct{}
+
+func (m *MockPollStore) Get(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	if postID != MockPollPostID && postID != MockClosedPollPostID {
+		return nil, ErrNotFound
+	}
+
+	closesAt := time.Now().Add(time.Hour)
+	if postID == MockClosedPollPostID {
+		closesAt = time.Now().Add(-time.Hour)
+	}
+
+	return &Poll{
+		ClosesAt:    closesAt,
+		Closed:      postID == MockClosedPollPostID,
+		Options:     []PollOption{{ID: 1, Text: "gophers"}, {ID: 2, Text: "rustaceans"}},
+		ViewerVotes: []int64{},
+	}, nil
+}
+
+func (m *MockPollStore) Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error {
+	poll, err := m.Get(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+	if poll.Closed {
+		return ErrPollClosed
+	}
+
+	for _, id := range optionIDs {
+		if id != 1 && id != 2 {
+			return ErrNotFound
+		}
+	}
+
+	return nil
+}
+
+func (m *MockPollStore) Unvote(ctx context.Context, postID, userID int64) error {
+	poll, err := m.Get(ctx, postID, userID)
+	if err != nil {
+		return err
+	}
+	if poll.Closed {
+		return ErrPollClosed
+	}
+
+	return nil
+}
+
+// MockPinStore keeps the pins of every user together, in memory.
+type MockPinStore struct {
+	pinned []int64
+}
+
+func (m *MockPinStore) Create(ctx context.Context, userID, postID int64, max int) error {
+	if slices.Contains(m.pinned, postID) {
+		return nil
+	}
+	if len(m.pinned) >= max {
+		return ErrTooManyPins
+	}
+
+	m.pinned = append(m.pinned, postID)
+	return nil
+}
+
+func (m *MockPinStore) Delete(ctx context.Context, userID, postID int64) error {
+	i := slices.Index(m.pinned, postID)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	m.pinned = slices.Delete(m.pinned, i, i+1)
+	return nil
+}
+
+func (m *MockPinStore) Exists(ctx context.Context, userID, postID int64) (bool, error) {
+	return slices.Contains(m.pinned, postID), nil
+}
+
+func (m *MockPinStore) Reorder(ctx context.Context, userID int64, postIDs []int64) error {
+	sorted, pinned := slices.Sorted(slices.Values(postIDs)), slices.Sorted(slices.Values(m.pinned))
+	if !slices.Equal(sorted, pinned) {
+		return ErrNotFound
+	}
+
+	m.pinned = slices.Clone(postIDs)
+	return nil
+}
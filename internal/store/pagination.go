@@ -1,6 +1,8 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -69,6 +71,74 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	return fq, nil
 }
 
+// ErrInvalidCursor is returned for a cursor that was not handed out by the API.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorQuery pages through a list by id. The cursor is opaque to clients:
+// they pass back the next_cursor of the previous page.
+type CursorQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor" validate:"max=100"`
+}
+
+func (cq CursorQuery) Parse(r *http.Request) (CursorQuery, error) {
+
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+		cq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+
+		_, err := decodeCursor(cursor)
+		if err != nil {
+			return cq, err
+		}
+		cq.Cursor = cursor
+	}
+
+	return cq, nil
+}
+
+// afterID returns the id the page starts after, 0 for the first page.
+func (cq CursorQuery) afterID() int64 {
+
+	id, _ := decodeCursor(cq.Cursor)
+	return id
+}
+
+func encodeCursor(id int64) string {
+
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+
+	if cursor == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
 // ............
 func parseTime(s string) string {
 
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+var ErrTooManyPins = errors.New("too many pinned posts")
+
+// PinStore keeps the posts users pin on their profile. Only published posts
+// can be pinned, posts leaving published or moved to the trash are unpinned.
+type PinStore struct {
+	db *sql.DB
+}
+
+// pinnedPostIDs lists the pinned posts of the user, filtered like
+// PostStore.GetPinned, in the order they are shown.
+func pinnedPostIDs(ctx context.Context, tx *sql.Tx, userID int64) ([]int64, error) {
+
+	query := `
+		SELECT pp.post_id
+		FROM pinned_posts pp
+			JOIN posts p ON p.id = pp.post_id
+		WHERE pp.user_id = $1 AND p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY pp.position, pp.created_at`
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// withPins runs fn in a transaction holding the pinned posts of the user.
+func (s *PinStore) withPins(ctx context.Context, userID int64, fn func(tx *sql.Tx, pinned []int64) error) error {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		// serializes the changes to the pins of the user
+		_, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID)
+		if err != nil {
+			return err
+		}
+
+		pinned, err := pinnedPostIDs(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		return fn(tx, pinned)
+	})
+}
+
+// Create pins the post last, pinning it again is a no-op. ErrTooManyPins
+// when the user already pinned max posts.
+func (s *PinStore) Create(ctx context.Context, userID, postID int64, max int) error {
+
+	return s.withPins(ctx, userID, func(tx *sql.Tx, pinned []int64) error {
+
+		if slices.Contains(pinned, postID) {
+			return nil
+		}
+
+		if len(pinned) >= max {
+			return ErrTooManyPins
+		}
+
+		query := `
+			INSERT INTO pinned_posts (user_id, post_id, position)
+			SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM pinned_posts WHERE user_id = $1
+			HAVING EXISTS (SELECT 1 FROM posts WHERE id = $2 AND status = 'published' AND deleted_at IS NULL)
+			ON CONFLICT (user_id, post_id) DO NOTHING`
+
+		_, err := tx.ExecContext(ctx, query, userID, postID)
+
+		return err
+	})
+}
+
+// unpin removes the post from the pins of its author.
+func unpin(ctx context.Context, tx *sql.Tx, postID int64) error {
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM pinned_posts WHERE post_id = $1`, postID)
+
+	return err
+}
+
+func (s *PinStore) Delete(ctx context.Context, userID, postID int64) error {
+
+	query := `DELETE FROM pinned_posts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PinStore) Exists(ctx context.Context, userID, postID int64) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM pinned_posts WHERE user_id = $1 AND post_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&exists)
+
+	return exists, err
+}
+
+// Reorder puts the pinned posts of the user in the given order, ErrNotFound
+// unless it lists each of them once.
+func (s *PinStore) Reorder(ctx context.Context, userID int64, postIDs []int64) error {
+
+	return s.withPins(ctx, userID, func(tx *sql.Tx, pinned []int64) error {
+
+		sorted := slices.Sorted(slices.Values(postIDs))
+		slices.Sort(pinned)
+
+		if !slices.Equal(sorted, pinned) {
+			return ErrNotFound
+		}
+
+		query := `
+			UPDATE pinned_posts pp
+			SET position = o.position
+			FROM unnest($2::bigint[]) WITH ORDINALITY AS o(post_id, position)
+			WHERE pp.user_id = $1 AND pp.post_id = o.post_id`
+
+		_, err := tx.ExecContext(ctx, query, userID, pq.Array(postIDs))
+
+		return err
+	})
+}
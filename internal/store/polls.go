@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrPollClosed = errors.New("poll is closed")
+
+// Poll is attached to a post when it is created. Votes are anonymous: only
+// the tallies and the viewer's own votes are ever returned.
+type Poll struct {
+	MultipleChoice bool         `json:"multiple_choice"`
+	ClosesAt       time.Time    `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	Options        []PollOption `json:"options"`
+	// users who voted, less than the votes on multiple-choice polls
+	VoterCount int `json:"voter_count"`
+	// the options the viewer voted for
+	ViewerVotes []int64 `json:"viewer_votes"`
+}
+
+type PollOption struct {
+	ID    int64  `json:"id"`
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// pollColumn scans the JSON object built by pollQuery into a post's poll,
+// left nil when the post has none.
+type pollColumn struct {
+	poll **Poll
+}
+
+func (c pollColumn) Scan(src any) error {
+
+	var data []byte
+
+	switch src := src.(type) {
+	case nil:
+		*c.poll = nil
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Poll", src)
+	}
+
+	*c.poll = &Poll{}
+
+	return json.Unmarshal(data, *c.poll)
+}
+
+// pollQuery returns a subquery building the poll of the post identified by
+// the given SQL expression, with the votes of the viewer. The votes of users
+// in the trash are left out of the tallies.
+func pollQuery(id, viewer string) string {
+
+	return fmt.Sprintf(`(
+		SELECT json_build_object(
+			'multiple_choice', pl.multiple_choice,
+			'closes_at', pl.closes_at,
+			'closed', pl.closes_at <= NOW(),
+			'options', (
+				SELECT json_agg(json_build_object(
+					'id', o.id,
+					'text', o.text,
+					'votes', (
+						SELECT COUNT(*) FROM poll_votes v JOIN users vu ON vu.id = v.user_id
+						WHERE v.option_id = o.id AND vu.deleted_at IS NULL
+					)
+				) ORDER BY o.position)
+				FROM poll_options o WHERE o.post_id = pl.post_id
+			),
+			'voter_count', (
+				SELECT COUNT(DISTINCT v.user_id) FROM poll_votes v JOIN users vu ON vu.id = v.user_id
+				WHERE v.post_id = pl.post_id AND vu.deleted_at IS NULL
+			),
+			'viewer_votes', (
+				SELECT COALESCE(json_agg(v.option_id ORDER BY v.option_id), '[]')
+				FROM poll_votes v WHERE v.post_id = pl.post_id AND v.user_id = %[2]s
+			)
+		)
+		FROM polls pl WHERE pl.post_id = %[1]s
+	)`, id, viewer)
+}
+
+// createPoll stores the poll of a new post, filling in the option ids.
+func createPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *Poll) error {
+
+	query := `INSERT INTO polls (post_id, multiple_choice, closes_at) VALUES ($1, $2, $3)`
+
+	_, err := tx.ExecContext(ctx, query, postID, poll.MultipleChoice, poll.ClosesAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range poll.Options {
+		query := `INSERT INTO poll_options (post_id, position, text) VALUES ($1, $2, $3) RETURNING id`
+
+		err := tx.QueryRowContext(ctx, query, postID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	poll.ViewerVotes = []int64{}
+
+	return nil
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// Get returns the poll of the post as seen by the viewer.
+func (s *PollStore) Get(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+
+	query := `SELECT ` + pollQuery("$1", "$2")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var poll *Poll
+
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(pollColumn{&poll})
+	if err != nil {
+		return nil, err
+	}
+
+	if poll == nil {
+		return nil, ErrNotFound
+	}
+
+	return poll, nil
+}
+
+// Vote replaces the votes of the user with the given options, ErrNotFound
+// when the post has no poll or an option is not one of its own.
+func (s *PollStore) Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error {
+
+	return s.withOpenPoll(ctx, postID, func(tx *sql.Tx) error {
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM poll_votes WHERE post_id = $1 AND user_id = $2`, postID, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO poll_votes (option_id, post_id, user_id)
+			SELECT id, post_id, $2 FROM poll_options WHERE post_id = $1 AND id = ANY($3)`
+
+		res, err := tx.ExecContext(ctx, query, postID, userID, pq.Array(optionIDs))
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows != int64(len(optionIDs)) {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// Unvote withdraws the votes of the user, ErrNotFound when they did not
+// vote.
+func (s *PollStore) Unvote(ctx context.Context, postID, userID int64) error {
+
+	return s.withOpenPoll(ctx, postID, func(tx *sql.Tx) error {
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM poll_votes WHERE post_id = $1 AND user_id = $2`, postID, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// withOpenPoll runs fn in a transaction holding the poll of the post, which
+// serializes its votes, ErrPollClosed once it is closed.
+func (s *PollStore) withOpenPoll(ctx context.Context, postID int64, fn func(*sql.Tx) error) error {
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		var open bool
+
+		err := tx.QueryRowContext(ctx, `SELECT closes_at > NOW() FROM polls WHERE post_id = $1 FOR UPDATE`, postID).Scan(&open)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if !open {
+			return ErrPollClosed
+		}
+
+		return fn(tx)
+	})
+}
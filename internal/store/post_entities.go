@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/high-la/gopher-social/internal/entities"
+)
+
+const (
+	EntityFieldTitle   = "title"
+	EntityFieldContent = "content"
+)
+
+// PostEntity is a mention or a hashtag found in the title or the content of
+// a post. Start and End are offsets in characters of the entity in that
+// field, sigil included, End being exclusive.
+type PostEntity struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+	// the mentioned user
+	UserID *int64 `json:"user_id,omitempty"`
+}
+
+// PostEntities scans the JSON array built by postEntitiesQuery.
+type PostEntities []PostEntity
+
+func (e *PostEntities) Scan(src any) error {
+
+	switch src := src.(type) {
+	case nil:
+		*e = PostEntities{}
+		return nil
+	case []byte:
+		return json.Unmarshal(src, e)
+	case string:
+		return json.Unmarshal([]byte(src), e)
+	default:
+		return fmt.Errorf("cannot scan %T into PostEntities", src)
+	}
+}
+
+// Entities drops the fields the entities package does not know about.
+func (e PostEntities) Entities() []entities.Entity {
+
+	found := make([]entities.Entity, 0, len(e))
+
+	for _, pe := range e {
+		found = append(found, entities.Entity{Type: pe.Type, Start: pe.Start, End: pe.End, Text: pe.Text})
+	}
+
+	return found
+}
+
+// postEntitiesQuery returns a subquery building the entities of the post
+// identified by the given SQL expression, title first.
+func postEntitiesQuery(id string) string {
+
+	return fmt.Sprintf(`(
+		SELECT COALESCE(json_agg(e ORDER BY e.field DESC, e.start), '[]')
+		FROM (
+			SELECT 'mention' AS type, m.field, m.start_offset AS start, m.end_offset AS "end", m.text, m.user_id
+			FROM post_mentions m WHERE m.post_id = %[1]s
+			UNION ALL
+			SELECT 'hashtag', h.field, h.start_offset, h.end_offset, h.tag, NULL
+			FROM post_hashtags h WHERE h.post_id = %[1]s
+		) e
+	)`, id)
+}
+
+// replaceEntities stores the entities of the post in place of the previous
+// ones. Mentions are resolved to users, the ones of unknown usernames are
+// dropped from the post.
+func replaceEntities(ctx context.Context, tx *sql.Tx, post *Post) error {
+
+	for _, query := range []string{
+		`DELETE FROM post_mentions WHERE post_id = $1`,
+		`DELETE FROM post_hashtags WHERE post_id = $1`,
+	} {
+		_, err := tx.ExecContext(ctx, query, post.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	stored := PostEntities{}
+
+	for _, entity := range post.Entities {
+
+		switch entity.Type {
+		case entities.TypeMention:
+			query := `
+				INSERT INTO post_mentions
+					(post_id, user_id, field, start_offset, end_offset, text)
+				SELECT $1, id, $2, $3, $4, $5 FROM users WHERE username = $5 AND deleted_at IS NULL
+				RETURNING user_id`
+
+			var userID int64
+
+			err := tx.QueryRowContext(ctx, query, post.ID, entity.Field, entity.Start, entity.End, entity.Text).Scan(&userID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				return err
+			}
+
+			entity.UserID = &userID
+
+		case entities.TypeHashtag:
+			query := `
+				INSERT INTO post_hashtags
+					(post_id, tag, field, start_offset, end_offset)
+				VALUES
+					($1, $2, $3, $4, $5)`
+
+			_, err := tx.ExecContext(ctx, query, post.ID, entity.Text, entity.Field, entity.Start, entity.End)
+			if err != nil {
+				return err
+			}
+
+		default:
+			continue
+		}
+
+		stored = append(stored, entity)
+	}
+
+	post.Entities = stored
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// PostRevision is a post as it was at one of its versions.
+type PostRevision struct {
+	PostID  int64    `json:"post_id"`
+	Version int      `json:"version"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	// the author or a moderator, null once their account is gone
+	Editor    *User  `json:"editor"`
+	CreatedAt string `json:"created_at"`
+}
+
+type PostRevisionStore struct {
+	db *sql.DB
+}
+
+// createRevision records the current version of the post, as changed by
+// the editor.
+func createRevision(ctx context.Context, tx *sql.Tx, post *Post, editorID int64) error {
+
+	query := `
+		INSERT INTO post_revisions
+			(post_id, version, title, content, tags, editor_id)
+		VALUES
+			($1, $2, $3, $4, $5, $6)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, post.ID, post.Version, post.Title, post.Content, pq.Array(post.Tags), editorID)
+
+	return err
+}
+
+var postRevisionColumns = `
+	r.post_id, r.version, r.title, r.content, r.tags, r.created_at, e.id, e.username`
+
+func scanPostRevision(row interface{ Scan(...any) error }, revision *PostRevision) error {
+
+	var editorID sql.NullInt64
+	var editorName sql.NullString
+
+	err := row.Scan(
+		&revision.PostID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Content,
+		pq.Array(&revision.Tags),
+		&revision.CreatedAt,
+		&editorID,
+		&editorName,
+	)
+	if err != nil {
+		return err
+	}
+
+	if editorID.Valid {
+		revision.Editor = &User{ID: editorID.Int64, Username: editorName.String}
+	}
+
+	return nil
+}
+
+// GetByPostID lists the revisions of the post, latest first.
+func (s *PostRevisionStore) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+
+	query := `
+		SELECT ` + postRevisionColumns + `
+		FROM post_revisions r
+			LEFT JOIN users e ON e.id = r.editor_id
+		WHERE r.post_id = $1
+		ORDER BY r.version DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+
+	for rows.Next() {
+		var revision PostRevision
+
+		if err := scanPostRevision(rows, &revision); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *PostRevisionStore) Get(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+
+	query := `
+		SELECT ` + postRevisionColumns + `
+		FROM post_revisions r
+			LEFT JOIN users e ON e.id = r.editor_id
+		WHERE r.post_id = $1 AND r.version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revision PostRevision
+
+	err := scanPostRevision(s.db.QueryRowContext(ctx, query, postID, version), &revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
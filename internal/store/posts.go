@@ -5,56 +5,236 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 )
 
+const (
+	VisibilityPublic = "public"
+	// only the followers of the author
+	VisibilityFollowers = "followers"
+	// only the author
+	VisibilityPrivate = "private"
+)
+
+const (
+	// only seen by the author
+	PostStatusDraft = "draft"
+	// published by the publisher worker once publish_at has come
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+// publishedOnly keeps the posts that are not drafts or scheduled.
+var publishedOnly = []string{PostStatusPublished}
+
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID         int64    `json:"id"`
+	Content    string   `json:"content"`
+	Title      string   `json:"title"`
+	UserID     int64    `json:"user_id"`
+	Tags       []string `json:"tags"`
+	Visibility string   `json:"visibility"`
+	Status     string   `json:"status"`
+	// when the post was or will be published, null for drafts
+	PublishAt *time.Time `json:"publish_at"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+	// set while the post is in the trash
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	Version      int        `json:"version"`
+	CommentCount int        `json:"comment_count"`
+	RepostCount  int        `json:"repost_count"`
+	// set on quote posts, null again once the original is deleted
+	QuotedPostID *int64 `json:"quoted_post_id"`
+	QuotedPost   *Post  `json:"quoted_post,omitempty"`
+	// mentions and hashtags parsed from the title and the content
+	Entities    PostEntities `json:"entities"`
+	Attachments Attachments  `json:"attachments"`
+	Poll        *Poll        `json:"poll"`
+	User        User         `json:"user"`
 }
 
+// PostWithMetadata is a post as seen by a given user.
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comment_count"`
+	Reactions  Reactions `json:"reactions"`
+	Bookmarked bool      `json:"bookmarked"`
+	// pinned by the author on their profile
+	Pinned bool `json:"pinned"`
+	// set on feed entries brought by a repost
+	RepostedBy *User `json:"reposted_by,omitempty"`
+}
+
+// quotedPost holds the columns of the post quoted by another, all null
+// unless there is one.
+type quotedPost struct {
+	id         sql.NullInt64
+	userID     sql.NullInt64
+	title      sql.NullString
+	content    sql.NullString
+	visibility sql.NullString
+	status     sql.NullString
+	createdAt  sql.NullString
+	username   sql.NullString
+}
+
+// quotedPostColumns selects the quoted post of p, joined by quotedPostJoin.
+const (
+	quotedPostColumns = `q.id, q.user_id, q.title, q.content, q.visibility, q.status, q.created_at, qu.username`
+	quotedPostJoin    = `
+		LEFT JOIN (posts q JOIN users qu ON qu.id = q.user_id AND qu.deleted_at IS NULL)
+			ON q.id = p.quoted_post_id AND q.deleted_at IS NULL`
+)
+
+// visibleTo returns the condition for the post of the given alias to be
+// visible to the user identified by the given SQL expression. Authors see
+// their drafts, the others only see published posts.
+func visibleTo(post, viewer string) string {
+
+	return fmt.Sprintf(`(
+		%[1]s.user_id = %[2]s OR
+		%[1]s.status = 'published' AND (
+			%[1]s.visibility = 'public' OR
+			(%[1]s.visibility = 'followers' AND EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = %[1]s.user_id AND vf.follower_id = %[2]s))
+		)
+	)`, post, viewer)
+}
+
+func (q *quotedPost) dest() []any {
+
+	return []any{&q.id, &q.userID, &q.title, &q.content, &q.visibility, &q.status, &q.createdAt, &q.username}
+}
+
+func (q *quotedPost) post() *Post {
+
+	if !q.id.Valid {
+		return nil
+	}
+
+	return &Post{
+		ID:         q.id.Int64,
+		UserID:     q.userID.Int64,
+		Title:      q.title.String,
+		Content:    q.content.String,
+		Visibility: q.visibility.String,
+		Status:     q.status.String,
+		CreatedAt:  q.createdAt.String,
+		User:       User{ID: q.userID.Int64, Username: q.username.String},
+	}
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
+// GetUserFeed lists the posts and reposts of the user and of the users they
+// follow, most recent activity first by default, along with the reactions
+// to them and the one of the user. Only the published posts visible to the
+// user are listed.
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
 
+	// a post shared several times shows up once, for its latest activity
+	source := `
+		SELECT DISTINCT ON (post_id) post_id, reposted_by, activity_at
+		FROM (
+			SELECT id AS post_id, NULL::bigint AS reposted_by, publish_at AS activity_at
+			FROM posts
+			WHERE user_id = $1 OR user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1)
+			UNION ALL
+			SELECT rp.post_id, rp.user_id, rp.created_at
+			FROM reposts rp
+			JOIN users ru ON ru.id = rp.user_id AND ru.deleted_at IS NULL
+			WHERE rp.user_id = $1 OR rp.user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1)
+		) activity
+		ORDER BY post_id, activity_at DESC NULLS LAST`
+
+	return queryPostsWithMetadata(ctx, s.db, userID, fq, source, publishedOnly)
+}
+
+// GetDrafts lists the drafts and scheduled posts of the user, filtered like
+// the feed.
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+
+	source := `
+		SELECT id AS post_id, NULL::bigint AS reposted_by, created_at AS activity_at
+		FROM posts
+		WHERE user_id = $1`
+
+	return queryPostsWithMetadata(ctx, s.db, userID, fq, source, []string{PostStatusDraft, PostStatusScheduled})
+}
+
+// GetByUserID lists the published posts of the user visible to the viewer,
+// filtered like the feed.
+func (s *PostStore) GetByUserID(ctx context.Context, userID, viewerID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+
+	source := `
+		SELECT id AS post_id, NULL::bigint AS reposted_by, publish_at AS activity_at
+		FROM posts
+		WHERE user_id = $7`
+
+	return queryPostsWithMetadata(ctx, s.db, viewerID, fq, source, publishedOnly, userID)
+}
+
+// GetPinned lists up to limit posts pinned by the user, in their order,
+// that are visible to the viewer.
+func (s *PostStore) GetPinned(ctx context.Context, userID, viewerID int64, limit int) ([]PostWithMetadata, error) {
+
+	source := `
+		SELECT post_id, NULL::bigint AS reposted_by, position AS activity_at
+		FROM pinned_posts
+		WHERE user_id = $7`
+
+	fq := PaginatedFeedQuery{Limit: limit, Sort: "asc"}
+
+	return queryPostsWithMetadata(ctx, s.db, viewerID, fq, source, publishedOnly, userID)
+}
+
+// queryPostsWithMetadata lists the posts of the source visible to the viewer,
+// with one of the statuses and matching the search and tags of the feed
+// query. Quoted posts the viewer can not see are left out. The source selects
+// post_id, reposted_by and activity_at, what entries are sorted by, and can
+// refer to the viewer as $1 and to the args from $7.
+func queryPostsWithMetadata(ctx context.Context, db *sql.DB, viewerID int64, fq PaginatedFeedQuery, source string, statuses []string, args ...any) ([]PostWithMetadata, error) {
+
 	query := fmt.Sprintf(`
 		SELECT
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			p.id, p.user_id, p.title, p.content, p.visibility, p.status, p.publish_at, p.created_at, p.version, p.tags, p.quoted_post_id,
 			u.username,
-			COUNT(c.id) AS comment_count
-		FROM posts p
-			LEFT JOIN comments c ON c.post_id = p.id
-			LEFT JOIN users u ON p.user_id = u.id
-			LEFT JOIN followers f ON f.follower_id = p.user_id
+			(SELECT COUNT(*) FROM comments c JOIN users cu ON cu.id = c.user_id WHERE c.post_id = p.id AND c.deleted_at IS NULL AND cu.deleted_at IS NULL) AS comment_count,
+			(SELECT COUNT(*) FROM reposts rp JOIN users rpu ON rpu.id = rp.user_id WHERE rp.post_id = p.id AND rpu.deleted_at IS NULL) AS repost_count,
+			%s AS reactions,
+			(SELECT kind FROM post_reactions r WHERE r.post_id = p.id AND r.user_id = $1) AS viewer_reaction,
+			EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) AS bookmarked,
+			EXISTS (SELECT 1 FROM pinned_posts pp WHERE pp.post_id = p.id AND pp.user_id = p.user_id) AS pinned,
+			%s AS entities,
+			%s AS attachments,
+			%s AS poll,
+			ru.id, ru.username,
+			%s
+		FROM (%s) s
+			JOIN posts p ON p.id = s.post_id
+			JOIN users u ON p.user_id = u.id
+			LEFT JOIN users ru ON ru.id = s.reposted_by
+			%s AND %s
 		WHERE 
-			(f.user_id = $1 OR p.user_id = $1) AND
+			%s AND
+			p.deleted_at IS NULL AND u.deleted_at IS NULL AND (s.reposted_by IS NULL OR ru.deleted_at IS NULL) AND
+			p.status = ANY($6::varchar[]) AND
 			(p.title ILIKE '%%' || $4 || '%%' OR p.content ILIKE '%%' || $4 || '%%') AND
 			(COALESCE(cardinality($5::varchar[]), 0) = 0 OR p.tags @> $5::varchar[])
-		GROUP BY p.id, u.username
-		ORDER BY p.id %s
+		ORDER BY s.activity_at %s, p.id %s
 		LIMIT $2
-		OFFSET $3`, fq.Sort)
+		OFFSET $3`, reactionCountsQuery(postReactions, "p.id"), postEntitiesQuery("p.id"), attachmentsQuery("p.id"), pollQuery("p.id", "$1"), quotedPostColumns, source,
+		quotedPostJoin, visibleTo("q", "$1"), visibleTo("p", "$1"), fq.Sort, fq.Sort)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	args = append([]any{viewerID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags), pq.Array(statuses)}, args...)
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -68,75 +248,137 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	for rows.Next() {
 
 		var post PostWithMetadata
+		var reposterID sql.NullInt64
+		var reposterName sql.NullString
+		var quoted quotedPost
 
-		err := rows.Scan(
+		dest := []any{
 			&post.ID,
 			&post.UserID,
 			&post.Title,
 			&post.Content,
+			&post.Visibility,
+			&post.Status,
+			&post.PublishAt,
 			&post.CreatedAt,
 			&post.Version,
 			pq.Array(&post.Tags),
+			&post.QuotedPostID,
 			&post.User.Username,
 			&post.CommentCount,
-		)
+			&post.RepostCount,
+			&post.Reactions.Counts,
+			&post.Reactions.ViewerReaction,
+			&post.Bookmarked,
+			&post.Pinned,
+			&post.Entities,
+			&post.Attachments,
+			pollColumn{&post.Poll},
+			&reposterID,
+			&reposterName,
+		}
+
+		err := rows.Scan(append(dest, quoted.dest()...)...)
 		if err != nil {
 			return nil, err
 		}
 
+		post.User.ID = post.UserID
+		post.QuotedPost = quoted.post()
+
+		if reposterID.Valid {
+			post.RepostedBy = &User{ID: reposterID.Int64, Username: reposterName.String}
+		}
+
 		feed = append(feed, post)
 	}
 
-	return feed, nil
+	return feed, rows.Err()
 }
 
 // ...................................................................
 
+// Create stores the post along with its entities, its poll if any and its
+// first revision. Published posts are published right away.
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
 
-	query := `
-		INSERT INTO posts
-			(content, title, user_id, tags)
-		Values ($1, $2, $3, $4) 
-		RETURNING id, created_at, updated_at`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+		query := `
+			INSERT INTO posts
+				(content, title, user_id, tags, quoted_post_id, visibility, status, publish_at)
+			Values ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $7 = 'published' THEN NOW() ELSE $8::timestamptz END) 
+			RETURNING id, version, publish_at, created_at, updated_at`
 
-	args := []any{post.Content, post.Title, post.UserID, pq.Array(post.Tags)}
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+		args := []any{post.Content, post.Title, post.UserID, pq.Array(post.Tags), post.QuotedPostID, post.Visibility, post.Status, post.PublishAt}
 
-	if err != nil {
-		return err
-	}
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&post.ID, &post.Version, &post.PublishAt, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if err := createRevision(ctx, tx, post, post.UserID); err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		return replaceEntities(ctx, tx, post)
+	})
 }
 
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 
 	query := `
 		SELECT 
-			id, user_id, title, content, created_at, updated_at, tags, version
-		FROM posts
-		WHERE id = $1`
+			p.id, p.user_id, p.title, p.content, p.visibility, p.status, p.publish_at, p.created_at, p.updated_at, p.tags, p.version, p.quoted_post_id,
+			u.username,
+			(SELECT COUNT(*) FROM comments c JOIN users cu ON cu.id = c.user_id WHERE c.post_id = p.id AND c.deleted_at IS NULL AND cu.deleted_at IS NULL) AS comment_count,
+			(SELECT COUNT(*) FROM reposts rp JOIN users rpu ON rpu.id = rp.user_id WHERE rp.post_id = p.id AND rpu.deleted_at IS NULL) AS repost_count,
+			` + postEntitiesQuery("p.id") + ` AS entities,
+			` + attachmentsQuery("p.id") + ` AS attachments,
+			` + pollQuery("p.id", "NULL") + ` AS poll,
+			` + quotedPostColumns + `
+		FROM posts p
+			JOIN users u ON u.id = p.user_id
+			` + quotedPostJoin + `
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND u.deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	var post Post
+	var quoted quotedPost
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	dest := []any{
 		&post.ID,
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.Visibility,
+		&post.Status,
+		&post.PublishAt,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
-	)
+		&post.QuotedPostID,
+		&post.User.Username,
+		&post.CommentCount,
+		&post.RepostCount,
+		&post.Entities,
+		&post.Attachments,
+		pollColumn{&post.Poll},
+	}
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(append(dest, quoted.dest()...)...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -147,56 +389,201 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 
 	}
 
+	post.User.ID = post.UserID
+	post.QuotedPost = quoted.post()
+
 	return &post, nil
 }
 
-func (s *PostStore) Update(ctx context.Context, post *Post) error {
+// Update saves the post along with its entities and a revision made by the
+// editor, unless it was updated since it was read. A post becoming
+// published is published right away, one leaving published is unpinned.
+func (s *PostStore) Update(ctx context.Context, post *Post, editorID int64) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		query := `
+			UPDATE posts
+			SET title = $1, content =$2, tags = $3, visibility = $4, version = version + 1,
+				publish_at = CASE
+					WHEN $7 <> 'published' THEN $8::timestamptz
+					WHEN status = 'published' THEN publish_at
+					ELSE NOW()
+				END,
+				status = $7
+			WHERE id = $5 AND version = $6
+			RETURNING version, publish_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		args := []any{post.Title, post.Content, pq.Array(post.Tags), post.Visibility, post.ID, post.Version, post.Status, post.PublishAt}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&post.Version, &post.PublishAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := createRevision(ctx, tx, post, editorID); err != nil {
+			return err
+		}
+
+		if post.Status != PostStatusPublished {
+			if err := unpin(ctx, tx, post.ID); err != nil {
+				return err
+			}
+		}
+
+		return replaceEntities(ctx, tx, post)
+	})
+}
+
+// GetMentions lists the posts mentioning the user, filtered like the feed.
+func (s *PostStore) GetMentions(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+
+	source := `
+		SELECT p.id AS post_id, NULL::bigint AS reposted_by, p.publish_at AS activity_at
+		FROM posts p
+		WHERE EXISTS (SELECT 1 FROM post_mentions m WHERE m.post_id = p.id AND m.user_id = $1)`
+
+	return queryPostsWithMetadata(ctx, s.db, userID, fq, source, publishedOnly)
+}
+
+// PublishScheduled publishes the scheduled posts whose time has come and
+// returns how many there were, leaving those in the trash scheduled.
+// Publishing makes a new version, so a concurrent edit of the scheduled post
+// fails, and its revision credits the author.
+func (s *PostStore) PublishScheduled(ctx context.Context) (int64, error) {
 
 	query := `
-		UPDATE posts
-		SET title = $1, content =$2, version = version + 1
-		WHERE id = $3 AND version = $4
-		RETURNING version`
+		WITH published AS (
+			UPDATE posts SET status = 'published', version = version + 1
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			RETURNING id, version, title, content, tags, user_id
+		)
+		INSERT INTO post_revisions
+			(post_id, version, title, content, tags, editor_id)
+		SELECT id, version, title, content, tags, user_id FROM published`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	args := []any{post.Title, post.Content, post.ID, post.Version}
-
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(&post.Version)
+	res, err := s.db.ExecContext(ctx, query)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Delete moves the post to the trash and unpins it.
+func (s *PostStore) Delete(ctx context.Context, postID int64) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		if err := setDeletedAt(ctx, tx, "posts", postID, true); err != nil {
 			return err
 		}
+
+		return unpin(ctx, tx, postID)
+	})
+}
+
+// Restore takes the post back out of the trash.
+func (s *PostStore) Restore(ctx context.Context, postID int64) error {
+
+	return setDeletedAt(ctx, s.db, "posts", postID, false)
+}
+
+// GetDeletedByID returns the post only if it is in the trash, without its
+// metadata.
+func (s *PostStore) GetDeletedByID(ctx context.Context, id int64) (*Post, error) {
+
+	posts, err := s.listDeleted(ctx, `p.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		return nil, ErrNotFound
 	}
 
-	return nil
+	return &posts[0], nil
 }
 
-func (s *PostStore) Delete(ctx context.Context, postID int64) error {
+// GetDeletedByUserID lists the posts of the user in the trash, most
+// recently deleted first.
+func (s *PostStore) GetDeletedByUserID(ctx context.Context, userID int64) ([]Post, error) {
+
+	return s.listDeleted(ctx, `p.user_id = $1`, userID)
+}
 
-	query := `DELETE FROM posts WHERE id = $1`
+func (s *PostStore) listDeleted(ctx context.Context, where string, arg int64) ([]Post, error) {
+
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.tags, p.visibility, p.status, p.created_at, p.updated_at, p.deleted_at
+		FROM posts p
+		WHERE ` + where + ` AND p.deleted_at IS NOT NULL
+		ORDER BY p.deleted_at DESC, p.id DESC`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
-
+	rows, err := s.db.QueryContext(ctx, query, arg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
+	defer rows.Close()
+
+	posts := []Post{}
+
+	for rows.Next() {
+
+		var post Post
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			pq.Array(&post.Tags),
+			&post.Visibility,
+			&post.Status,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		post.User.ID = post.UserID
+
+		posts = append(posts, post)
 	}
 
-	if rows == 0 {
-		return ErrNotFound
+	return posts, rows.Err()
+}
+
+// PurgeDeleted removes for good the posts deleted before the given time and
+// returns how many there were.
+func (s *PostStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+
+	query := `DELETE FROM posts WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return res.RowsAffected()
 }
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// ReactionKinds is the fixed set of reactions, keyed by the name used in
+// the API.
+var ReactionKinds = map[string]string{
+	"like":  "👍",
+	"love":  "❤️",
+	"laugh": "😂",
+	"wow":   "😮",
+	"sad":   "😢",
+	"angry": "😠",
+}
+
+// ReactionCounts counts the reactions to a post or a comment by kind. It
+// scans the JSON object built by reactionCountsQuery.
+type ReactionCounts map[string]int
+
+func (c *ReactionCounts) Scan(src any) error {
+
+	switch src := src.(type) {
+	case nil:
+		*c = ReactionCounts{}
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", src)
+	}
+}
+
+type Reactions struct {
+	Counts ReactionCounts `json:"counts"`
+	// the kind the viewer reacted with, null when they did not react
+	ViewerReaction *string `json:"viewer_reaction"`
+}
+
+type reactionTarget struct {
+	table  string
+	column string
+}
+
+var (
+	postReactions    = reactionTarget{table: "post_reactions", column: "post_id"}
+	commentReactions = reactionTarget{table: "comment_reactions", column: "comment_id"}
+)
+
+// reactionCountsQuery returns a subquery building the counts of the target
+// identified by the given SQL expression, for use in a select list. Users in
+// the trash are left out.
+func reactionCountsQuery(target reactionTarget, id string) string {
+
+	return fmt.Sprintf(`(
+		SELECT COALESCE(json_object_agg(k.kind, k.count), '{}')
+		FROM (
+			SELECT r.kind, COUNT(*) AS count
+			FROM %s r JOIN users ru ON ru.id = r.user_id
+			WHERE r.%s = %s AND ru.deleted_at IS NULL
+			GROUP BY r.kind
+		) k
+	)`, target.table, target.column, id)
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// SetOnPost reacts to a post, replacing the previous reaction of the user.
+func (s *ReactionStore) SetOnPost(ctx context.Context, postID, userID int64, kind string) error {
+
+	return s.set(ctx, postReactions, postID, userID, kind)
+}
+
+// RemoveFromPost withdraws the reaction of the user, ErrNotFound when they
+// did not react with that kind.
+func (s *ReactionStore) RemoveFromPost(ctx context.Context, postID, userID int64, kind string) error {
+
+	return s.remove(ctx, postReactions, postID, userID, kind)
+}
+
+func (s *ReactionStore) GetForPost(ctx context.Context, postID, viewerID int64) (*Reactions, error) {
+
+	return s.get(ctx, postReactions, postID, viewerID)
+}
+
+// SetOnComment reacts to a comment, replacing the previous reaction of the user.
+func (s *ReactionStore) SetOnComment(ctx context.Context, commentID, userID int64, kind string) error {
+
+	return s.set(ctx, commentReactions, commentID, userID, kind)
+}
+
+// RemoveFromComment withdraws the reaction of the user, ErrNotFound when
+// they did not react with that kind.
+func (s *ReactionStore) RemoveFromComment(ctx context.Context, commentID, userID int64, kind string) error {
+
+	return s.remove(ctx, commentReactions, commentID, userID, kind)
+}
+
+func (s *ReactionStore) GetForComment(ctx context.Context, commentID, viewerID int64) (*Reactions, error) {
+
+	return s.get(ctx, commentReactions, commentID, viewerID)
+}
+
+func (s *ReactionStore) set(ctx context.Context, target reactionTarget, id, userID int64, kind string) error {
+
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s
+			(%[2]s, user_id, kind)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (%[2]s, user_id) DO UPDATE
+			SET kind = EXCLUDED.kind, created_at = NOW()`, target.table, target.column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, userID, kind)
+
+	return err
+}
+
+func (s *ReactionStore) remove(ctx context.Context, target reactionTarget, id, userID int64, kind string) error {
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND user_id = $2 AND kind = $3`, target.table, target.column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID, kind)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *ReactionStore) get(ctx context.Context, target reactionTarget, id, viewerID int64) (*Reactions, error) {
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s,
+			(SELECT kind FROM %s WHERE %s = $1 AND user_id = $2)`,
+		reactionCountsQuery(target, "$1"), target.table, target.column)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var reactions Reactions
+
+	err := s.db.QueryRowContext(ctx, query, id, viewerID).Scan(&reactions.Counts, &reactions.ViewerReaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reactions, nil
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	Token     string    `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Create stores a refresh token. The token is expected to be already hashed.
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token)
+	})
+}
+
+// Rotate spends the given plain refresh token and stores next in its family.
+// Presenting a token that was already spent revokes the whole family and
+// returns ErrRefreshTokenReused.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token string, next *RefreshToken) error {
+
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		// 01. find and lock the presented token
+		current, used, revoked, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if revoked || current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		// 02. a spent token means it leaked, kill the family
+		if used {
+			reused = true
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		// 03. spend it and issue the next one in the same family
+		err = s.markUsed(ctx, tx, current.ID)
+		if err != nil {
+			return err
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+
+		return s.create(ctx, tx, next)
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.revokeFamily(ctx, tx, familyID)
+	})
+}
+
+// RevokeFamilyByToken revokes the family of the given plain token, provided
+// it belongs to the user.
+func (s *RefreshTokenStore) RevokeFamilyByToken(ctx context.Context, token string, userID int64) error {
+
+	query := `
+		UPDATE refresh_tokens
+			SET revoked_at = NOW()
+		WHERE
+			revoked_at IS NULL AND
+			family_id = (SELECT family_id FROM refresh_tokens WHERE token = $1 AND user_id = $2)`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken, userID)
+
+	return err
+}
+
+func (s *RefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+
+	query := `
+		UPDATE refresh_tokens
+			SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+
+	return err
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, bool, bool, error) {
+
+	query := `
+		SELECT
+			id, user_id, family_id, expiry, used_at IS NOT NULL, revoked_at IS NOT NULL
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt := &RefreshToken{}
+	var used, revoked bool
+
+	err := tx.QueryRowContext(ctx, query, hashToken).
+		Scan(&rt.ID, &rt.UserID, &rt.FamilyID, &rt.Expiry, &used, &revoked)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, false, false, ErrNotFound
+		default:
+			return nil, false, false, err
+		}
+	}
+
+	return rt, used, revoked, nil
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+
+	query := `
+		INSERT INTO refresh_tokens
+			(token, user_id, family_id, expiry)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(ctx, query, token.Token, token.UserID, token.FamilyID, token.Expiry).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *RefreshTokenStore) markUsed(ctx context.Context, tx *sql.Tx, id int64) error {
+
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+
+	return err
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+
+	query := `
+		UPDATE refresh_tokens
+			SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+
+	return err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RepostStore struct {
+	db *sql.DB
+}
+
+// Create reposts a post, reposting it again is a no-op.
+func (s *RepostStore) Create(ctx context.Context, userID, postID int64) error {
+
+	query := `
+		INSERT INTO reposts
+			(user_id, post_id)
+		VALUES
+			($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+
+	return err
+}
+
+func (s *RepostStore) Delete(ctx context.Context, userID, postID int64) error {
+
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
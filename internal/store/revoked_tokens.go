@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RevokedTokenStore is the Postgres backed JWT denylist, used when the
+// redis cache is disabled.
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+// Revoke denylists a token id until its expiry, pruning the entries that
+// can no longer match a valid token.
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiry time.Time) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expiry < NOW()`)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO revoked_tokens
+				(jti, expiry)
+			VALUES
+				($1, $2)
+			ON CONFLICT (jti) DO NOTHING`
+
+		_, err = tx.ExecContext(ctx, query, jti, expiry)
+
+		return err
+	})
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+
+	err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// RevokeUser invalidates every token issued to the user before now. The
+// record is only relevant until expiry, when all those tokens have expired.
+func (s *RevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiry time.Time) error {
+
+	query := `
+		INSERT INTO user_token_revocations
+			(user_id, revoked_at, expiry)
+		VALUES
+			($1, NOW(), $2)
+		ON CONFLICT (user_id) DO UPDATE
+			SET revoked_at = EXCLUDED.revoked_at, expiry = EXCLUDED.expiry`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, expiry)
+
+	return err
+}
+
+// UserRevokedAt returns when the user's tokens were last revoked, or the
+// zero time if there is no revocation in effect.
+func (s *RevokedTokenStore) UserRevokedAt(ctx context.Context, userID int64) (time.Time, error) {
+
+	query := `
+		SELECT revoked_at
+		FROM user_token_revocations
+		WHERE user_id = $1 AND expiry > NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revokedAt time.Time
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&revokedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return time.Time{}, nil
+		default:
+			return time.Time{}, err
+		}
+	}
+
+	return revokedAt, nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Session is a login on a device. Its ID is the family ID of the refresh
+// tokens issued to it, and the sid claim of its access tokens.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  string     `json:"created_at"`
+	LastSeenAt string     `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// Create starts a session along with the first token of its refresh family.
+// The token is expected to be already hashed.
+func (s *SessionStore) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+
+	refreshTokens := &RefreshTokenStore{s.db}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		query := `
+			INSERT INTO sessions
+				(id, user_id, user_agent, ip_address)
+			VALUES
+				($1, $2, $3, $4)
+			RETURNING created_at, last_seen_at`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, session.ID, session.UserID, session.UserAgent, session.IPAddress).
+			Scan(&session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return err
+		}
+
+		token.UserID = session.UserID
+		token.FamilyID = session.ID
+
+		return refreshTokens.create(ctx, tx, token)
+	})
+}
+
+// GetByUserID lists the sessions not revoked and seen since the given time.
+func (s *SessionStore) GetByUserID(ctx context.Context, userID int64, seenSince time.Time) ([]Session, error) {
+
+	query := `
+		SELECT
+			id, user_id, user_agent, ip_address, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2
+		ORDER BY last_seen_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, seenSince)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+
+		var session Session
+
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records the session activity, at most once a minute to spare
+// writes, and reports whether the session has been revoked. Unknown
+// sessions return ErrNotFound.
+func (s *SessionStore) Touch(ctx context.Context, id, ip string) (bool, error) {
+
+	query := `
+		WITH touched AS (
+			UPDATE sessions
+				SET last_seen_at = NOW(), ip_address = $2
+			WHERE id = $1 AND revoked_at IS NULL AND last_seen_at < NOW() - INTERVAL '1 minute'
+		)
+		SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+
+	err := s.db.QueryRowContext(ctx, query, id, ip).Scan(&revoked)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return revoked, nil
+}
+
+// Revoke ends a session of the user and revokes its refresh tokens.
+func (s *SessionStore) Revoke(ctx context.Context, id string, userID int64) error {
+
+	refreshTokens := &RefreshTokenStore{s.db}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		query := `
+			UPDATE sessions
+				SET revoked_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, id, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return refreshTokens.revokeFamily(ctx, tx, id)
+	})
+}
+
+func (s *SessionStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+
+	query := `
+		UPDATE sessions
+			SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+
+	return err
+}
+
+// DeleteInactive removes the sessions revoked or last seen before the given time.
+func (s *SessionStore) DeleteInactive(ctx context.Context, before time.Time) (int64, error) {
+
+	query := `DELETE FROM sessions WHERE revoked_at < $1 OR last_seen_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -17,41 +17,182 @@ type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
 		Create(context.Context, *Post) error
-		Update(context.Context, *Post) error
+		Update(context.Context, *Post, int64) error
 		Delete(context.Context, int64) error
+		Restore(context.Context, int64) error
+		GetDeletedByID(context.Context, int64) (*Post, error)
+		GetDeletedByUserID(context.Context, int64) ([]Post, error)
+		PurgeDeleted(context.Context, time.Time) (int64, error)
 		//
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetMentions(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetDrafts(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetByUserID(context.Context, int64, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetPinned(context.Context, int64, int64, int) ([]PostWithMetadata, error)
+		PublishScheduled(context.Context) (int64, error)
 	}
 	Users interface {
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
+		GetInactiveByEmail(context.Context, string) (*User, error)
 		Create(context.Context, *sql.Tx, *User) error
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
 		Activate(context.Context, string) error
+		RotateInvitation(context.Context, int64, string, time.Duration) error
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		DeleteUnactivated(context.Context, time.Time) (int64, error)
 		Delete(context.Context, int64) error
+		Restore(context.Context, int64) error
+		GetDeletedByID(context.Context, int64) (*User, error)
+		Purge(context.Context, int64) error
+		PurgeDeleted(context.Context, time.Time) (int64, error)
+		CreatePasswordReset(context.Context, int64, string, time.Duration) error
+		ResetPassword(context.Context, string, string) (*User, error)
+		CreateMagicLink(context.Context, int64, string, time.Duration) error
+		ConsumeMagicLink(context.Context, string) (*User, error)
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByPostID(context.Context, int64, CursorQuery) ([]Comment, string, error)
+		GetReplies(context.Context, int64, CursorQuery) ([]Comment, string, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		GetListedByID(context.Context, int64) (*Comment, error)
 		Create(context.Context, *Comment) error
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64) error
+		Restore(context.Context, int64) error
+		GetDeletedByID(context.Context, int64) (*Comment, error)
+		GetDeletedByUserID(context.Context, int64) ([]Comment, error)
+		PurgeDeleted(context.Context, time.Time) (int64, error)
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
+		IsFollowing(ctx context.Context, followerID, userID int64) (bool, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	LoginAttempts interface {
+		Create(context.Context, *LoginAttempt) error
+		CountFailuresByEmail(context.Context, string, time.Time) (int, error)
+		CountFailuresByIP(context.Context, string, time.Time) (int, error)
+	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		Rotate(context.Context, string, *RefreshToken) error
+		RevokeFamily(context.Context, string) error
+		RevokeFamilyByToken(context.Context, string, int64) error
+		RevokeAllForUser(context.Context, int64) error
+	}
+	RevokedTokens interface {
+		Revoke(context.Context, string, time.Time) error
+		IsRevoked(context.Context, string) (bool, error)
+		RevokeUser(context.Context, int64, time.Time) error
+		UserRevokedAt(context.Context, int64) (time.Time, error)
+	}
+	APIKeys interface {
+		Create(context.Context, *APIKey) error
+		GetByUserID(context.Context, int64) ([]APIKey, error)
+		GetByID(context.Context, int64, int64) (*APIKey, error)
+		GetByToken(context.Context, string) (*APIKey, error)
+		Touch(context.Context, int64) error
+		Delete(context.Context, int64, int64) error
+	}
+	TwoFactor interface {
+		Get(context.Context, int64) (*TOTP, error)
+		Enroll(context.Context, int64, string) error
+		Enable(context.Context, int64, int64, []string) error
+		Disable(context.Context, int64) error
+		UseStep(context.Context, int64, int64) error
+		UseRecoveryCode(context.Context, int64, string) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session, *RefreshToken) error
+		GetByUserID(context.Context, int64, time.Time) ([]Session, error)
+		Touch(context.Context, string, string) (bool, error)
+		Revoke(context.Context, string, int64) error
+		RevokeAllForUser(context.Context, int64) error
+		DeleteInactive(context.Context, time.Time) (int64, error)
+	}
+	Identities interface {
+		GetUser(context.Context, string, string) (*User, error)
+		GetByUserID(context.Context, int64) ([]Identity, error)
+		Link(context.Context, *Identity) error
+		Unlink(context.Context, int64, string) error
+		CreateUser(context.Context, *User, *Identity) error
+		CreateState(context.Context, *OIDCState) error
+		ConsumeState(context.Context, string) (*OIDCState, error)
+		DeleteExpiredStates(context.Context) (int64, error)
+	}
+	Reactions interface {
+		SetOnPost(context.Context, int64, int64, string) error
+		RemoveFromPost(context.Context, int64, int64, string) error
+		GetForPost(context.Context, int64, int64) (*Reactions, error)
+		SetOnComment(context.Context, int64, int64, string) error
+		RemoveFromComment(context.Context, int64, int64, string) error
+		GetForComment(context.Context, int64, int64) (*Reactions, error)
+	}
+	Bookmarks interface {
+		Create(context.Context, int64, int64) error
+		Delete(context.Context, int64, int64) error
+		Exists(context.Context, int64, int64) (bool, error)
+		GetByUserID(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+	}
+	Reposts interface {
+		Create(context.Context, int64, int64) error
+		Delete(context.Context, int64, int64) error
+	}
+	AuditLogs interface {
+		Create(context.Context, *AuditLog) error
+		UpdateStatus(context.Context, int64, int) error
+	}
+	PostRevisions interface {
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+		Get(context.Context, int64, int) (*PostRevision, error)
+	}
+	Attachments interface {
+		Create(context.Context, *Attachment, int) error
+		Detach(context.Context, int64, int64) error
+		GetDetached(context.Context, int) ([]Attachment, error)
+		Delete(context.Context, int64) error
+	}
+	Polls interface {
+		Get(ctx context.Context, postID, viewerID int64) (*Poll, error)
+		Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error
+		Unvote(ctx context.Context, postID, userID int64) error
+	}
+	Pins interface {
+		Create(context.Context, int64, int64, int) error
+		Delete(context.Context, int64, int64) error
+		Exists(context.Context, int64, int64) (bool, error)
+		Reorder(context.Context, int64, []int64) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 
 	return Storage{
 
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:         &PostStore{db},
+		Users:         &UserStore{db},
+		Comments:      &CommentStore{db},
+		Followers:     &FollowerStore{db},
+		Roles:         &RoleStore{db},
+		LoginAttempts: &LoginAttemptStore{db},
+		RefreshTokens: &RefreshTokenStore{db},
+		RevokedTokens: &RevokedTokenStore{db},
+		APIKeys:       &APIKeyStore{db},
+		TwoFactor:     &TwoFactorStore{db},
+		Identities:    &IdentityStore{db},
+		Sessions:      &SessionStore{db},
+		AuditLogs:     &AuditLogStore{db},
+		Reactions:     &ReactionStore{db},
+		Bookmarks:     &BookmarkStore{db},
+		Reposts:       &RepostStore{db},
+		PostRevisions: &PostRevisionStore{db},
+		Attachments:   &AttachmentStore{db},
+		Polls:         &PollStore{db},
+		Pins:          &PinStore{db},
 	}
 }
 
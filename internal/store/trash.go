@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// setDeletedAt moves a row of the table to the trash, or takes it back out
+// of it, and fails with ErrNotFound when the row is not where expected.
+func setDeletedAt(ctx context.Context, db execer, table string, id int64, deleted bool) error {
+
+	query := `UPDATE ` + table + ` SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	if !deleted {
+		query = `UPDATE ` + table + ` SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
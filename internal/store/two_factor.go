@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+)
+
+type TOTP struct {
+	UserID       int64  `json:"user_id"`
+	Secret       string `json:"-"`
+	Enabled      bool   `json:"enabled"`
+	LastUsedStep int64  `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type TwoFactorStore struct {
+	db *sql.DB
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID int64) (*TOTP, error) {
+
+	query := `
+		SELECT
+			user_id, secret, enabled, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	totp := &TOTP{}
+
+	err := s.db.QueryRowContext(ctx, query, userID).
+		Scan(&totp.UserID, &totp.Secret, &totp.Enabled, &totp.LastUsedStep, &totp.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return totp, nil
+}
+
+// Enroll stores a pending secret, replacing any previous unconfirmed one.
+// It returns ErrConflict when two-factor authentication is already enabled.
+func (s *TwoFactorStore) Enroll(ctx context.Context, userID int64, secret string) error {
+
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+			SET secret = EXCLUDED.secret, created_at = NOW()
+		WHERE user_totp.enabled = false`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// Enable confirms the pending secret, spending the step of the confirmation
+// code, and replaces the recovery codes. Codes are expected to be hashed.
+func (s *TwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		query := `
+			UPDATE user_totp
+				SET enabled = true, last_used_step = $2
+			WHERE user_id = $1 AND enabled = false`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID, step)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userID int64) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+
+		return err
+	})
+}
+
+// UseStep spends a time step. Replaying a code of the same or an older step
+// returns ErrConflict.
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+
+	query := `
+		UPDATE user_totp
+			SET last_used_step = $2
+		WHERE user_id = $1 AND enabled = true AND last_used_step < $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// UseRecoveryCode spends the unused recovery code matching the plain code.
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+
+	query := `
+		UPDATE totp_recovery_codes
+			SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM totp_recovery_codes
+			WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+			LIMIT 1
+		)`
+
+	hash := sha256.Sum256([]byte(code))
+	hashCode := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, hashCode)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, codes []string) error {
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO totp_recovery_codes (user_id, code) VALUES ($1, $2)`
+
+	for _, code := range codes {
+		_, err = tx.ExecContext(ctx, query, userID, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -51,6 +51,11 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+func (p *password) Compare(text string) error {
+
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+}
+
 func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExpiray time.Duration) error {
 
 	// transaction wrapper
@@ -164,7 +169,7 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 			u.id, u.username, u.email, u.created_at, u.is_active
 		FROM users u
 		JOIN user_invitations ui ON u.id = ui.user_id
-		WHERE ui.token = $1 AND ui.expiry > $2`
+		WHERE ui.token = $1 AND ui.expiry > $2 AND u.deleted_at IS NULL`
 
 	hash := sha256.Sum256([]byte(token))
 	hashToken := hex.EncodeToString(hash[:])
@@ -221,21 +226,300 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+// RotateInvitation replaces the pending invitations of a user with a new one.
+func (s *UserStore) RotateInvitation(ctx context.Context, userID int64, token string, invitationExpiray time.Duration) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		err := s.deleteUserInvitations(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationExpiray, userID)
+	})
+}
+
+// DeleteExpiredInvitations removes the expired invitations of activated
+// users. The last invitation of an inactive user is kept, DeleteUnactivated
+// reads its expiry.
+func (s *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+
+	query := `
+		DELETE FROM user_invitations ui
+		WHERE ui.expiry < $1
+		AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = ui.user_id AND u.is_active = false)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteUnactivated removes the users that never activated their account
+// and whose latest invitation expired before the given time, along with
+// their invitations. Users without any invitation fall back on created_at.
+func (s *UserStore) DeleteUnactivated(ctx context.Context, expiredBefore time.Time) (int64, error) {
+
+	query := `
+		WITH expired AS (
+			SELECT u.id
+			FROM users u
+			LEFT JOIN user_invitations ui ON ui.user_id = u.id
+			WHERE u.is_active = false
+			GROUP BY u.id
+			HAVING COALESCE(MAX(ui.expiry), u.created_at) < $1
+		), invitations AS (
+			DELETE FROM user_invitations WHERE user_id IN (SELECT id FROM expired)
+		)
+		DELETE FROM users WHERE id IN (SELECT id FROM expired)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, expiry time.Duration) error {
+
+	query := `
+		INSERT INTO password_resets
+			(token, user_id, expiry)
+		VALUES
+			($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, token, userID, time.Now().Add(expiry))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) ResetPassword(ctx context.Context, token, newPassword string) (*User, error) {
+
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		var err error
+
+		// 01. find the user that this token belongs to
+		user, err = s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		// 02. hash and store the new password
+		err = user.Password.Set(newPassword)
+		if err != nil {
+			return err
+		}
+
+		err = s.updatePassword(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		// 03. invalidate every outstanding reset token
+		return s.deletePasswordResets(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+
+	query := `
+		SELECT
+			u.id, u.username, u.email, u.created_at, u.is_active
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2 AND u.deleted_at IS NULL`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).
+		Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.IsActive)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateMagicLink stores a login token for the user, replacing the previous
+// one. The token is expected to be already hashed.
+func (s *UserStore) CreateMagicLink(ctx context.Context, userID int64, token string, expiry time.Duration) error {
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM magic_links WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO magic_links
+				(token, user_id, expiry)
+			VALUES
+				($1, $2, $3)`
+
+		_, err = tx.ExecContext(ctx, query, token, userID, time.Now().Add(expiry))
+
+		return err
+	})
+}
+
+// ConsumeMagicLink spends the plain token and returns the active user it
+// belongs to.
+func (s *UserStore) ConsumeMagicLink(ctx context.Context, token string) (*User, error) {
+
+	var user *User
+	var expired bool
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// 01. spend the token, a concurrent consumer finds nothing left
+		var userID int64
+		var expiry time.Time
+
+		query := `DELETE FROM magic_links WHERE token = $1 RETURNING user_id, expiry`
+
+		err := tx.QueryRowContext(ctx, query, hashToken).Scan(&userID, &expiry)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// an expired token is spent all the same
+		if expiry.Before(time.Now()) {
+			expired = true
+			return nil
+		}
+
+		// 02. the account must still be active
+		user, err = getUserByID(ctx, tx, userID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if expired {
+		return nil, ErrNotFound
+	}
+
+	return user, nil
+}
+
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 
+	return getUserByID(ctx, s.db, userID)
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getUserByID(ctx context.Context, q rowQuerier, userID int64) (*User, error) {
+
+	return getUser(ctx, q, `u.id = $1 AND is_active = true AND deleted_at IS NULL`, userID)
+}
+
+// GetDeletedByID returns the user only if they are in the trash.
+func (s *UserStore) GetDeletedByID(ctx context.Context, userID int64) (*User, error) {
+
+	return getUser(ctx, s.db, `u.id = $1 AND deleted_at IS NOT NULL`, userID)
+}
+
+func getUser(ctx context.Context, q rowQuerier, where string, userID int64) (*User, error) {
+
 	query := `
 		SELECT 
 			u.id, username, email, password, created_at, r.*
 		FROM users u
 		JOIN roles r ON u.role_id = r.id 
-		WHERE u.id = $1 AND is_active = true`
+		WHERE ` + where
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	user := &User{}
 
-	err := s.db.QueryRowContext(ctx, query, userID).
+	err := q.QueryRowContext(ctx, query, userID).
 		Scan(
 			&user.ID,
 			&user.Username,
@@ -260,8 +544,39 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	return user, nil
 }
 
+// Delete moves the user to the trash, hiding them along with their posts and
+// comments until they are restored or purged.
 func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 
+	return setDeletedAt(ctx, s.db, "users", userID, true)
+}
+
+// Restore takes the user back out of the trash.
+func (s *UserStore) Restore(ctx context.Context, userID int64) error {
+
+	return setDeletedAt(ctx, s.db, "users", userID, false)
+}
+
+// PurgeDeleted removes for good the users deleted before the given time,
+// along with everything they own, and returns how many there were.
+func (s *UserStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+
+	query := `DELETE FROM users WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Purge removes the user for good, without going through the trash.
+func (s *UserStore) Purge(ctx context.Context, userID int64) error {
+
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 
 		err := s.delete(ctx, tx, userID)
@@ -298,10 +613,50 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	query := `
 		SELECT 
-			id, username, email, password, created_at
+			u.id, username, email, password, created_at, r.*
+		FROM users u
+		JOIN roles r ON u.role_id = r.id 
+		WHERE 
+			email = $1 AND is_active = true AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	err := s.db.QueryRowContext(ctx, query, email).
+		Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.Password.hash,
+			&user.CreatedAt,
+			//
+			&user.Role.ID,
+			&user.Role.Name,
+			&user.Role.Level,
+			&user.Role.Description,
+		)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) GetInactiveByEmail(ctx context.Context, email string) (*User, error) {
+
+	query := `
+		SELECT 
+			id, username, email, created_at, is_active
 		FROM users 
 		WHERE 
-			email = $1 AND is_active = true`
+			email = $1 AND is_active = false AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -309,7 +664,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	user := &User{}
 
 	err := s.db.QueryRowContext(ctx, query, email).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt)
+		Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.IsActive)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows: